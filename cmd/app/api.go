@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/0x0FACED/go-fortune/pkg/voronoi"
+)
+
+// Тело запроса /api/diagram. Нужно указать ровно одно из sites и generator
+type apiDiagramRequest struct {
+	// Явный список станций [[x, y], ...]
+	Sites [][]float64 `json:"sites"`
+	// Генерация станций внутри bbox
+	Generator *apiGenerator `json:"generator"`
+	// Область диаграммы
+	BBox *voronoi.BoundingBox `json:"bbox"`
+	// Замкнуть ячейки по границам bbox
+	CloseCells bool `json:"closeCells"`
+}
+
+type apiGenerator struct {
+	Stations int  `json:"stations"`
+	Random   bool `json:"random"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		fmt.Println("Ошибка записи JSON:", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, apiError{Error: err.Error()})
+}
+
+// станции из запроса: явные или сгенерированные
+func (req *apiDiagramRequest) points() ([]voronoi.Vertex, error) {
+	if req.BBox == nil {
+		return nil, errors.New("bbox обязателен")
+	}
+	if (req.Sites == nil) == (req.Generator == nil) {
+		return nil, errors.New("нужно указать ровно одно из sites и generator")
+	}
+
+	if req.Sites != nil {
+		points := make([]voronoi.Vertex, 0, len(req.Sites))
+		for i, site := range req.Sites {
+			if len(site) != 2 {
+				return nil, fmt.Errorf("sites[%d]: нужна пара [x, y], получено %d чисел", i, len(site))
+			}
+			points = append(points, voronoi.Vertex{X: site[0], Y: site[1]})
+		}
+		return points, nil
+	}
+
+	if req.Generator.Stations <= 0 || req.Generator.Stations > maxStations {
+		return nil, fmt.Errorf("generator.stations должно быть от 1 до %d", maxStations)
+	}
+	bbox := *req.BBox
+	width := int(bbox.Xr - bbox.Xl)
+	height := int(bbox.Yb - bbox.Yt)
+	if width < 1 || height < 1 {
+		return nil, errors.New("для генерации bbox должен быть не меньше 1x1")
+	}
+
+	var stations []Station
+	if req.Generator.Random {
+		stations = generateRandStations(req.Generator.Stations, width, height)
+	} else {
+		stations = generateFixStations(req.Generator.Stations, width, height)
+	}
+	points := stationsToVertices(stations)
+	// генераторы работают от (0, 0), сдвигаем в bbox
+	for i := range points {
+		points[i].X += bbox.Xl
+		points[i].Y += bbox.Yt
+	}
+	return points, nil
+}
+
+// http обработчик JSON API: POST /api/diagram.
+// Возвращает сайты, вершины, ребра и многоугольники ячеек, ошибки - {"error": "..."} с кодом 4xx
+func apiDiagramHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeAPIError(w, http.StatusMethodNotAllowed, errors.New("поддерживается только POST"))
+		return
+	}
+
+	var req apiDiagramRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 10<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("некорректный JSON: %w", err))
+		return
+	}
+
+	points, err := req.points()
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	diagram, err := voronoi.Build(points, *req.BBox, voronoi.Options{CloseCells: req.CloseCells})
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, voronoi.ErrCellNotFound) || errors.Is(err, voronoi.ErrCellNotClosed) {
+			status = http.StatusInternalServerError
+		}
+		writeAPIError(w, status, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, diagram.JSON())
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"math"
 	"math/rand"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
 	"time"
 
@@ -111,8 +115,9 @@ func prepareScatter(scatter *charts.Scatter) {
 	)
 }
 
-// Преобразуем voronoi границы в Echarts для отображения
-func voronoiToEcharts(stations []Station, diagram *voronoi.Diagram) *charts.Scatter {
+// Преобразуем voronoi границы в Echarts для отображения.
+// Если triangulation не nil, поверх рисуется триангуляция Делоне
+func voronoiToEcharts(stations []Station, diagram *voronoi.Diagram, triangulation *voronoi.Triangulation) *charts.Scatter {
 	scatter := charts.NewScatter()
 
 	points := make([]opts.ScatterData, 0)
@@ -133,6 +138,11 @@ func voronoiToEcharts(stations []Station, diagram *voronoi.Diagram) *charts.Scat
 		)
 
 	for _, edge := range diagram.Edges {
+		// границы bbox не рисуем
+		if edge.IsBorder() {
+			continue
+		}
+
 		line := charts.NewLine()
 		line.SetGlobalOptions(
 			charts.WithXAxisOpts(opts.XAxis{Show: opts.Bool(true)}),
@@ -151,22 +161,83 @@ func voronoiToEcharts(stations []Station, diagram *voronoi.Diagram) *charts.Scat
 		scatter.Overlap(line)
 	}
 
+	if triangulation != nil {
+		for _, edge := range triangulation.Edges {
+			a := diagram.Cells[edge[0]].Site()
+			b := diagram.Cells[edge[1]].Site()
+
+			line := charts.NewLine()
+			line.AddSeries("Делоне", []opts.LineData{
+				{Value: []float64{a.X, a.Y}},
+				{Value: []float64{b.X, b.Y}},
+			}).SetSeriesOptions(
+				charts.WithLineStyleOpts(opts.LineStyle{
+					Width: 1,
+					Color: "orange",
+					Type:  "dashed",
+				}),
+			)
+
+			scatter.Overlap(line)
+		}
+	}
+
 	return scatter
 }
 
+// Таблица площадей покрытия станций в порядке станций на входе
+func writeCoverageTable(w io.Writer, diagram *voronoi.Diagram) {
+	stats := diagram.Stats()
+
+	cells := slices.Clone(diagram.Cells)
+	slices.SortFunc(cells, func(a, b *voronoi.Cell) int { return a.Index() - b.Index() })
+
+	fmt.Fprintln(w, `<table id="coverage">`)
+	fmt.Fprintln(w, `<tr><th>#</th><th>X</th><th>Y</th><th>Площадь</th><th>Доля, %</th><th>Компактность</th></tr>`)
+	for _, cell := range cells {
+		site := cell.Site()
+		area := cell.Area()
+		share := 0.0
+		if stats.TotalArea > 0 {
+			share = area / stats.TotalArea * 100
+		}
+		fmt.Fprintf(w, "<tr><td>%d</td><td>%.1f</td><td>%.1f</td><td>%.1f</td><td>%.2f</td><td>%.3f</td></tr>\n",
+			cell.Index()+1, site.X, site.Y, area, share, cell.Compactness())
+	}
+	fmt.Fprintf(w, "<tr><th colspan=\"3\">Среднее (σ)</th><th>%.1f (%.1f)</th><th></th><th>%.3f</th></tr>\n",
+		stats.MeanArea, stats.StdDevArea, stats.MeanCompactness)
+	fmt.Fprintln(w, `</table>`)
+}
+
+// Наибольшее число итераций релаксации за запрос: каждая строит диаграмму заново
+const maxRelaxIterations = 50
+
 // http обработчик страницы с диаграмой и формой для ввода данных
 func diagramHandler(w http.ResponseWriter, r *http.Request) {
 	width := 1000
 	height := 1000
 	numStations := 12
+	relaxIterations := 0
 	var isRandom bool
+	var showDelaunay bool
 
 	if r.Method == http.MethodPost {
 		r.ParseForm()
-		width, _ = strconv.Atoi(r.FormValue("width"))
-		height, _ = strconv.Atoi(r.FormValue("height"))
-		numStations, _ = strconv.Atoi(r.FormValue("stations"))
+		var err error
+		width, height, numStations, err = stationParams(r.PostForm)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if raw := r.FormValue("relax"); raw != "" {
+			relaxIterations, err = strconv.Atoi(raw)
+			if err != nil || relaxIterations < 0 || relaxIterations > maxRelaxIterations {
+				http.Error(w, fmt.Sprintf("параметр relax должен быть целым числом от 0 до %d", maxRelaxIterations), http.StatusBadRequest)
+				return
+			}
+		}
 		isRandom = r.FormValue("random") == "true"
+		showDelaunay = r.FormValue("delaunay") == "true"
 	}
 	var stations []Station
 
@@ -183,23 +254,49 @@ func diagramHandler(w http.ResponseWriter, r *http.Request) {
 
 	bbox := voronoi.NewBoundingBox(0, float64(width), 0, float64(height))
 
+	// равномерно расставляем станции релаксацией Ллойда
+	if relaxIterations > 0 {
+		relaxed, err := voronoi.Relax(points, bbox, relaxIterations, voronoi.RelaxOptions{Tolerance: 1e-3})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		points = relaxed
+		for i, p := range points {
+			stations[i] = Station{X: p.X, Y: p.Y}
+		}
+	}
+
 	logger := logger.New()
 	defer logger.ClearLogs()
 
-	diagram := voronoi.CreateDiagram(points, bbox, false, logger)
+	// ячейки замыкаем, чтобы посчитать площади покрытия
+	diagram, err := voronoi.Build(points, bbox, voronoi.Options{CloseCells: true, Tracer: logger})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	scatter := voronoiToEcharts(stations, diagram)
+	var triangulation *voronoi.Triangulation
+	if showDelaunay {
+		triangulation = diagram.Delaunay()
+	}
+
+	scatter := voronoiToEcharts(stations, diagram, triangulation)
 
 	fmt.Fprintln(w, static.Part1)
 
-	err := scatter.Render(w)
+	err = scatter.Render(w)
 	if err != nil {
 		fmt.Println("Ошибка рендеринга диаграммы:", err)
 	}
 
+	writeCoverageTable(w, diagram)
+
 	fmt.Fprintln(w, static.Part2)
 
 	// Вставляем логи в HTML
+	logger.UpdateLogs()
 	for _, log := range logger.Logs {
 		fmt.Fprintln(w, log)
 	}
@@ -207,8 +304,234 @@ func diagramHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, static.Part3)
 }
 
+// Ответ /locate
+type locateResponse struct {
+	// Номер станции в порядке генерации
+	Index int     `json:"index"`
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+}
+
+// целочисленный параметр запроса со значением по умолчанию
+func intParam(q url.Values, name string, def int) (int, error) {
+	raw := q.Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	val, err := strconv.Atoi(raw)
+	if err != nil || val <= 0 {
+		return 0, fmt.Errorf("параметр %s должен быть положительным целым числом", name)
+	}
+	return val, nil
+}
+
+// Наибольшее число генерируемых станций за запрос
+const maxStations = 100000
+
+// параметры расстановки станций: width, height, stations
+func stationParams(q url.Values) (width, height, numStations int, err error) {
+	if width, err = intParam(q, "width", 1000); err != nil {
+		return
+	}
+	if height, err = intParam(q, "height", 1000); err != nil {
+		return
+	}
+	if numStations, err = intParam(q, "stations", 12); err != nil {
+		return
+	}
+	if numStations > maxStations {
+		err = fmt.Errorf("станций должно быть не больше %d", maxStations)
+	}
+	return
+}
+
+func stationsToVertices(stations []Station) []voronoi.Vertex {
+	points := make([]voronoi.Vertex, 0, len(stations))
+	for _, station := range stations {
+		points = append(points, voronoi.Vertex{X: station.X, Y: station.Y})
+	}
+	return points
+}
+
+// http обработчик поиска станции, обслуживающей точку ?x=&y=.
+// Станции расставляются сеткой (как на странице без флага random) по параметрам width, height, stations
+func locateHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	x, errX := strconv.ParseFloat(q.Get("x"), 64)
+	y, errY := strconv.ParseFloat(q.Get("y"), 64)
+	if errX != nil || errY != nil {
+		http.Error(w, "параметры x и y должны быть числами", http.StatusBadRequest)
+		return
+	}
+
+	width, height, numStations, err := stationParams(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	points := stationsToVertices(generateFixStations(numStations, width, height))
+
+	bbox := voronoi.NewBoundingBox(0, float64(width), 0, float64(height))
+	diagram, err := voronoi.Build(points, bbox, voronoi.Options{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cell := voronoi.NewLocator(diagram).Locate(voronoi.Vertex{X: x, Y: y})
+	if cell == nil {
+		http.Error(w, "точка вне области диаграммы", http.StatusNotFound)
+		return
+	}
+
+	site := cell.Site()
+	resp := locateResponse{Index: cell.Index(), X: site.X, Y: site.Y}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// http обработчик диаграммы в SVG.
+// Параметры как у формы: width, height, stations, random; fill=true - залить ячейки
+func svgHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	width, height, numStations, err := stationParams(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var stations []Station
+	if q.Get("random") == "true" {
+		stations = generateRandStations(numStations, width, height)
+	} else {
+		stations = generateFixStations(numStations, width, height)
+	}
+	points := stationsToVertices(stations)
+
+	bbox := voronoi.NewBoundingBox(0, float64(width), 0, float64(height))
+	diagram, err := voronoi.Build(points, bbox, voronoi.Options{CloseCells: true})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	err = diagram.WriteSVG(w, voronoi.SVGOptions{FillCells: q.Get("fill") == "true"})
+	if err != nil {
+		fmt.Println("Ошибка записи SVG:", err)
+	}
+}
+
+// http обработчик диаграммы в GeoJSON.
+// GET - станции по параметрам width, height, stations, random;
+// POST - станции из тела запроса (GeoJSON с точками), bbox по width и height
+func geoJSONHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	width, height, numStations, err := stationParams(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var points []voronoi.Vertex
+	switch r.Method {
+	case http.MethodGet:
+		if q.Get("random") == "true" {
+			points = stationsToVertices(generateRandStations(numStations, width, height))
+		} else {
+			points = stationsToVertices(generateFixStations(numStations, width, height))
+		}
+	case http.MethodPost:
+		points, err = voronoi.ReadGeoJSONSites(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	bbox := voronoi.NewBoundingBox(0, float64(width), 0, float64(height))
+	diagram, err := voronoi.Build(points, bbox, voronoi.Options{CloseCells: true})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	err = diagram.WriteGeoJSON(w)
+	if err != nil {
+		fmt.Println("Ошибка записи GeoJSON:", err)
+	}
+}
+
+// Ответ /api/steps
+type stepsResponse struct {
+	BBox  voronoi.BoundingBox `json:"bbox"`
+	Sites []voronoi.Vertex    `json:"sites"`
+	Steps []voronoi.Step      `json:"steps"`
+}
+
+// Наибольшее число станций для /api/steps: каждый снимок содержит все ребра,
+// поэтому ответ растет как квадрат числа станций
+const maxStepsStations = 200
+
+// http обработчик снимков алгоритма по шагам для страницы /steps.
+// Параметры как у формы: width, height, stations, random
+func stepsAPIHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	width, height, numStations, err := stationParams(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if numStations > maxStepsStations {
+		http.Error(w, fmt.Sprintf("для пошагового режима станций должно быть не больше %d", maxStepsStations), http.StatusBadRequest)
+		return
+	}
+
+	var stations []Station
+	if q.Get("random") == "true" {
+		stations = generateRandStations(numStations, width, height)
+	} else {
+		stations = generateFixStations(numStations, width, height)
+	}
+	points := stationsToVertices(stations)
+
+	bbox := voronoi.NewBoundingBox(0, float64(width), 0, float64(height))
+	diagram, err := voronoi.Build(points, bbox, voronoi.Options{RecordSteps: true})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := stepsResponse{BBox: bbox, Steps: diagram.Steps}
+	for _, cell := range diagram.Cells {
+		resp.Sites = append(resp.Sites, cell.Site())
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// http обработчик страницы пошагового проигрывания
+func stepsPageHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, static.StepsPage)
+}
+
 func main() {
 	http.HandleFunc("/", diagramHandler)
+	http.HandleFunc("/locate", locateHandler)
+	http.HandleFunc("/diagram.svg", svgHandler)
+	http.HandleFunc("/diagram.geojson", geoJSONHandler)
+	http.HandleFunc("/api/diagram", apiDiagramHandler)
+	http.HandleFunc("/steps", stepsPageHandler)
+	http.HandleFunc("/api/steps", stepsAPIHandler)
 	fmt.Println("Сервер запущен на http://localhost:8080")
 	err := http.ListenAndServe(":8080", nil)
 	if err != nil {
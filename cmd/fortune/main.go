@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/0x0FACED/go-fortune/pkg/voronoi"
+)
+
+// Консольная утилита: читает сайты, строит диаграмму и пишет ее в нужном формате
+//
+//	fortune -in sites.csv -bbox 0,1000,0,1000 -close -format svg -out diagram.svg
+//	cat sites.json | fortune -informat json -format geojson
+func main() {
+	in := flag.String("in", "-", "файл с сайтами, - для stdin")
+	inFormat := flag.String("informat", "", "формат сайтов: csv, json, geojson (по умолчанию по расширению, иначе csv)")
+	bboxFlag := flag.String("bbox", "", "область xl,xr,yt,yb (по умолчанию - границы сайтов с отступом 10%)")
+	closeCells := flag.Bool("close", false, "замкнуть ячейки по границам bbox (для geojson и wkt всегда)")
+	format := flag.String("format", "json", "формат вывода: json, svg, geojson, wkt")
+	out := flag.String("out", "-", "файл для вывода, - для stdout")
+	eps := flag.Float64("eps", 0, "абсолютный допуск сравнения координат (0 - по умолчанию)")
+	relEps := flag.Float64("releps", 0, "допуск относительно большей стороны bbox")
+	flag.Parse()
+
+	opts := voronoi.Options{CloseCells: *closeCells, Epsilon: *eps, RelativeEpsilon: *relEps}
+	if err := run(*in, *inFormat, *bboxFlag, opts, *format, *out); err != nil {
+		fmt.Fprintln(os.Stderr, "fortune:", err)
+		os.Exit(1)
+	}
+}
+
+func run(in, inFormat, bboxFlag string, opts voronoi.Options, format, out string) error {
+	switch format {
+	case "json", "svg":
+	case "geojson", "wkt":
+		// ячейки в этих форматах - многоугольники, без замыкания они не строятся
+		opts.CloseCells = true
+	default:
+		return fmt.Errorf("неизвестный формат вывода %q", format)
+	}
+
+	sites, err := readSites(in, inFormat)
+	if err != nil {
+		return err
+	}
+
+	var bbox voronoi.BoundingBox
+	if bboxFlag != "" {
+		bbox, err = parseBBox(bboxFlag)
+		if err != nil {
+			return err
+		}
+	} else {
+		bbox = sitesBBox(sites)
+	}
+
+	diagram, err := voronoi.Build(sites, bbox, opts)
+	if err != nil {
+		return err
+	}
+	if len(diagram.Duplicates) > 0 {
+		fmt.Fprintf(os.Stderr, "fortune: отброшено дубликатов: %d\n", len(diagram.Duplicates))
+	}
+
+	if out == "-" {
+		return writeDiagram(os.Stdout, diagram, format, opts)
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := writeDiagram(f, diagram, format, opts); err != nil {
+		f.Close()
+		return err
+	}
+	// ошибка отложенной записи на диск приходит только из Close
+	return f.Close()
+}
+
+func writeDiagram(w io.Writer, diagram *voronoi.Diagram, format string, opts voronoi.Options) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diagram.JSON())
+	case "svg":
+		return diagram.WriteSVG(w, voronoi.SVGOptions{FillCells: opts.CloseCells})
+	case "geojson":
+		return diagram.WriteGeoJSON(w)
+	default:
+		return diagram.WriteWKT(w)
+	}
+}
+
+func readSites(in, inFormat string) ([]voronoi.Vertex, error) {
+	var r io.Reader = os.Stdin
+	if in != "-" {
+		f, err := os.Open(in)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+
+		if inFormat == "" {
+			inFormat = strings.TrimPrefix(strings.ToLower(filepath.Ext(in)), ".")
+		}
+	}
+
+	switch inFormat {
+	case "", "csv", "txt":
+		return readCSVSites(r)
+	case "json":
+		var pairs [][]float64
+		if err := json.NewDecoder(r).Decode(&pairs); err != nil {
+			return nil, fmt.Errorf("json: ожидается [[x, y], ...]: %w", err)
+		}
+		sites := make([]voronoi.Vertex, 0, len(pairs))
+		for i, p := range pairs {
+			if len(p) != 2 {
+				return nil, fmt.Errorf("json: сайт %d: нужна пара [x, y], получено %d чисел", i, len(p))
+			}
+			sites = append(sites, voronoi.Vertex{X: p[0], Y: p[1]})
+		}
+		return sites, nil
+	case "geojson":
+		return voronoi.ReadGeoJSONSites(r)
+	}
+	return nil, fmt.Errorf("неизвестный формат сайтов %q", inFormat)
+}
+
+// CSV: по сайту на строку "x,y". Строка-заголовок (нечисловая первая строка) пропускается
+func readCSVSites(r io.Reader) ([]voronoi.Vertex, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+
+	var sites []voronoi.Vertex
+	for line := 1; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return sites, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("csv строка %d: нужно два поля x,y", line)
+		}
+		x, errX := strconv.ParseFloat(record[0], 64)
+		y, errY := strconv.ParseFloat(record[1], 64)
+		if errX != nil || errY != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("csv строка %d: x и y должны быть числами", line)
+		}
+		sites = append(sites, voronoi.Vertex{X: x, Y: y})
+	}
+}
+
+func parseBBox(s string) (voronoi.BoundingBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return voronoi.BoundingBox{}, fmt.Errorf("bbox: нужно 4 числа xl,xr,yt,yb")
+	}
+	var vals [4]float64
+	for i, part := range parts {
+		val, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return voronoi.BoundingBox{}, fmt.Errorf("bbox: %w", err)
+		}
+		vals[i] = val
+	}
+	return voronoi.NewBoundingBox(vals[0], vals[1], vals[2], vals[3]), nil
+}
+
+// границы сайтов с отступом 10% (не меньше 1) с каждой стороны.
+// Сайты с NaN и Inf пропускаются: о них сообщит Build, указав сайт
+func sitesBBox(sites []voronoi.Vertex) voronoi.BoundingBox {
+	b := voronoi.NewBoundingBox(math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1))
+	for _, p := range sites {
+		if math.IsNaN(p.X) || math.IsNaN(p.Y) || math.IsInf(p.X, 0) || math.IsInf(p.Y, 0) {
+			continue
+		}
+		b.Xl = math.Min(b.Xl, p.X)
+		b.Xr = math.Max(b.Xr, p.X)
+		b.Yt = math.Min(b.Yt, p.Y)
+		b.Yb = math.Max(b.Yb, p.Y)
+	}
+	if b.Xl > b.Xr {
+		return voronoi.NewBoundingBox(0, 1, 0, 1)
+	}
+	mx := math.Max(1, (b.Xr-b.Xl)*0.1)
+	my := math.Max(1, (b.Yb-b.Yt)*0.1)
+	return voronoi.NewBoundingBox(b.Xl-mx, b.Xr+mx, b.Yt-my, b.Yb+my)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/0x0FACED/go-fortune/pkg/voronoi"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadSites(t *testing.T) {
+	cases := []struct {
+		name     string
+		file     string
+		inFormat string
+		content  string
+		want     []voronoi.Vertex
+		// подстрока ошибки, пусто - ошибки быть не должно
+		err string
+	}{
+		{name: "csv", file: "s.csv", content: "1,2\n3.5, 4\n", want: []voronoi.Vertex{{X: 1, Y: 2}, {X: 3.5, Y: 4}}},
+		{name: "csv header and comment", file: "s.csv", content: "x,y\n# комментарий\n1,2\n", want: []voronoi.Vertex{{X: 1, Y: 2}}},
+		{name: "csv extra fields", file: "s.txt", content: "1,2,a\n", want: []voronoi.Vertex{{X: 1, Y: 2}}},
+		{name: "csv no extension", file: "sites", content: "1,2\n", want: []voronoi.Vertex{{X: 1, Y: 2}}},
+		{name: "csv one field", file: "s.csv", content: "1,2\n3\n", err: "строка 2"},
+		{name: "csv not a number", file: "s.csv", content: "1,2\n3,y\n", err: "строка 2"},
+		{name: "json", file: "s.json", content: "[[1, 2], [3, 4]]", want: []voronoi.Vertex{{X: 1, Y: 2}, {X: 3, Y: 4}}},
+		{name: "json by flag", file: "s.txt", inFormat: "json", content: "[[1, 2]]", want: []voronoi.Vertex{{X: 1, Y: 2}}},
+		{name: "json short pair", file: "s.json", content: "[[1, 2], [3]]", err: "сайт 1"},
+		{name: "json long pair", file: "s.json", content: "[[1, 2, 3]]", err: "сайт 0"},
+		{name: "json object", file: "s.json", content: `{"x": 1}`, err: "json"},
+		{name: "geojson", file: "s.geojson", content: `{"type": "MultiPoint", "coordinates": [[1, 2], [3, 4]]}`, want: []voronoi.Vertex{{X: 1, Y: 2}, {X: 3, Y: 4}}},
+		{name: "unknown format", file: "s.xml", content: "<sites/>", err: "неизвестный формат"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := readSites(writeFile(t, c.file, c.content), c.inFormat)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("got error %v, want %q", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, c.want) {
+				t.Fatalf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSitesBBox(t *testing.T) {
+	nan, inf := math.NaN(), math.Inf(1)
+	cases := []struct {
+		name  string
+		sites []voronoi.Vertex
+		want  voronoi.BoundingBox
+	}{
+		{name: "empty", want: voronoi.NewBoundingBox(0, 1, 0, 1)},
+		{name: "margin 10%", sites: []voronoi.Vertex{{X: 0, Y: 0}, {X: 100, Y: 50}}, want: voronoi.NewBoundingBox(-10, 110, -5, 55)},
+		{name: "margin at least 1", sites: []voronoi.Vertex{{X: 3, Y: 4}}, want: voronoi.NewBoundingBox(2, 4, 3, 5)},
+		{name: "skips NaN and Inf", sites: []voronoi.Vertex{{X: 0, Y: 0}, {X: nan, Y: 1}, {X: 100, Y: -inf}, {X: 100, Y: 50}}, want: voronoi.NewBoundingBox(-10, 110, -5, 55)},
+		{name: "only NaN", sites: []voronoi.Vertex{{X: nan, Y: nan}}, want: voronoi.NewBoundingBox(0, 1, 0, 1)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := sitesBBox(c.sites); got != c.want {
+				t.Fatalf("got %+v, want %+v", got, c.want)
+			}
+		})
+	}
+}
+
+// сайт с NaN должен давать ошибку с номером сайта, а не ошибку bbox
+func TestRunReportsInvalidSite(t *testing.T) {
+	in := writeFile(t, "s.csv", "0,0\nNaN,1\n100,50\n")
+	err := run(in, "", "", voronoi.Options{}, "json", filepath.Join(t.TempDir(), "out.json"))
+	if !errors.Is(err, voronoi.ErrInvalidSite) || !strings.Contains(err.Error(), "sites[1]") {
+		t.Fatalf("got %v, want %v for sites[1]", err, voronoi.ErrInvalidSite)
+	}
+}
+
+func TestRunFormats(t *testing.T) {
+	in := writeFile(t, "s.csv", "10,10\n50,20\n30,70\n80,80\n")
+	cases := []struct {
+		format string
+		check  func(t *testing.T, out []byte)
+	}{
+		{"json", func(t *testing.T, out []byte) {
+			var d voronoi.DiagramJSON
+			if err := json.Unmarshal(out, &d); err != nil {
+				t.Fatal(err)
+			}
+			if len(d.Cells) != 4 || d.BBox != voronoi.NewBoundingBox(0, 100, 0, 100) {
+				t.Fatalf("got %d cells in %+v", len(d.Cells), d.BBox)
+			}
+		}},
+		{"svg", func(t *testing.T, out []byte) {
+			if !strings.HasPrefix(string(out), "<svg") || !strings.HasSuffix(strings.TrimSpace(string(out)), "</svg>") {
+				t.Fatalf("not an svg document: %.60q", out)
+			}
+		}},
+		{"geojson", func(t *testing.T, out []byte) {
+			var fc voronoi.FeatureCollection
+			if err := json.Unmarshal(out, &fc); err != nil {
+				t.Fatal(err)
+			}
+			if fc.Type != "FeatureCollection" || len(fc.Features) < 4 {
+				t.Fatalf("got %s with %d features", fc.Type, len(fc.Features))
+			}
+		}},
+		{"wkt", func(t *testing.T, out []byte) {
+			// geojson и wkt всегда строятся с замкнутыми ячейками
+			lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+			if len(lines) != 4 {
+				t.Fatalf("got %d polygons, want 4", len(lines))
+			}
+			for _, line := range lines {
+				if !strings.HasPrefix(line, "POLYGON ((") {
+					t.Fatalf("got %q", line)
+				}
+			}
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.format, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "out")
+			if err := run(in, "", "0,100,0,100", voronoi.Options{}, c.format, out); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.check(t, data)
+		})
+	}
+
+	if err := run(in, "", "", voronoi.Options{}, "png", filepath.Join(t.TempDir(), "out")); err == nil {
+		t.Fatal("unknown output format accepted")
+	}
+	if err := run(in, "", "0,100,0", voronoi.Options{}, "json", filepath.Join(t.TempDir(), "out")); err == nil {
+		t.Fatal("bbox with 3 numbers accepted")
+	}
+}
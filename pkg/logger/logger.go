@@ -140,6 +140,8 @@ var colorMap = map[string]string{
 	// Add more colors as needed
 }
 
+// UpdateLogs рендерит накопленный буфер в HTML (в Logs).
+// Вызывается один раз, когда логи нужны, а не на каждую запись
 func (z *ZapLogger) UpdateLogs() {
 	htmlLogs := ansiToHTML(z.logBuf.String())
 	z.Logs = []string{htmlLogs}
@@ -152,20 +154,16 @@ func (z *ZapLogger) ClearLogs() {
 
 func (z *ZapLogger) Info(wrappedMsg string, fields ...zap.Field) {
 	z.log.Info(wrappedMsg, fields...)
-	z.UpdateLogs()
 }
 
 func (z *ZapLogger) Debug(wrappedMsg string, fields ...zap.Field) {
 	z.log.Debug(wrappedMsg, fields...)
-	z.UpdateLogs()
 }
 
 func (z *ZapLogger) Error(wrappedMsg string, fields ...zap.Field) {
 	z.log.Error(wrappedMsg, fields...)
-	z.UpdateLogs()
 }
 
 func (z *ZapLogger) Fatal(wrappedMsg string, fields ...zap.Field) {
 	z.log.Fatal(wrappedMsg, fields...)
-	z.UpdateLogs()
 }
@@ -4,7 +4,7 @@ type BeachSection struct {
 	node        *rbtNode
 	site        Vertex
 	circleEvent *circleEvent
-	edge        *edge
+	edge        *Edge
 }
 
 func (s *BeachSection) bindToNode(node *rbtNode) {
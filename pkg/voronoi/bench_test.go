@@ -0,0 +1,59 @@
+package voronoi
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func benchSites(n int) []Vertex {
+	r := rand.New(rand.NewSource(int64(n)))
+	sites := make([]Vertex, n)
+	for i := range sites {
+		sites[i] = Vertex{r.Float64() * 1000, r.Float64() * 1000}
+	}
+	return sites
+}
+
+// размеры бенчмарков. 1e5 и 1e6 сайтов строятся секунды и десятки секунд,
+// поэтому включаются только с FORTUNE_BENCH_LARGE=1 (удобно с -benchtime 1x)
+func benchSizes() []int {
+	sizes := []int{10, 100, 1000, 10000}
+	if os.Getenv("FORTUNE_BENCH_LARGE") != "" {
+		sizes = append(sizes, 100000, 1000000)
+	}
+	return sizes
+}
+
+func BenchmarkCreateDiagram(b *testing.B) {
+	bbox := NewBoundingBox(0, 1000, 0, 1000)
+	for _, n := range benchSizes() {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			sites := benchSites(n)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				CreateDiagram(sites, bbox, true, nil)
+			}
+		})
+	}
+}
+
+func BenchmarkBuildPower(b *testing.B) {
+	bbox := NewBoundingBox(0, 1000, 0, 1000)
+	for _, n := range benchSizes() {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			r := rand.New(rand.NewSource(int64(n)))
+			sites := make([]WeightedSite, n)
+			for i, p := range benchSites(n) {
+				sites[i] = WeightedSite{Vertex: p, Weight: r.Float64() * 1e6 / float64(n)}
+			}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := BuildPower(sites, bbox, Options{CloseCells: true}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
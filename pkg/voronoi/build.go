@@ -0,0 +1,158 @@
+package voronoi
+
+import (
+	"fmt"
+	"math"
+)
+
+// Параметры построения диаграммы
+type Options struct {
+	// Замкнуть ячейки по границам bbox
+	CloseCells bool
+	// Трассировка хода алгоритма. nil - без трассировки
+	Tracer Tracer
+	// Наблюдатель за событиями алгоритма. nil - без наблюдателя
+	Observer Observer
+	// Записать снимок состояния после каждого события в Diagram.Steps.
+	// Каждый снимок содержит все ребра, поэтому память растет как O(n^2)
+	RecordSteps bool
+	// Абсолютный допуск сравнения координат: при обрезке ребер, замыкании ячеек
+	// и склейке близких вершин. 0 - не задан. Проход прямой и построение
+	// биссектрис от допуска не зависят (см. sweepTolerance)
+	Epsilon float64
+	// Допуск относительно большей стороны bbox. Итоговый допуск - наибольший
+	// из Epsilon и RelativeEpsilon*size; если не задан ни один, берется
+	// наибольший из defaultEpsilon и defaultRelativeEpsilon*size
+	RelativeEpsilon float64
+}
+
+// допуск по умолчанию: абсолютный для bbox до 1e3 и относительный для больших.
+// Для координат много меньше 1 нужно задать RelativeEpsilon
+const (
+	defaultEpsilon         = 1e-9
+	defaultRelativeEpsilon = 1e-12
+)
+
+// итоговый допуск для bbox
+func (o Options) tolerance(bbox BoundingBox) float64 {
+	size := math.Max(bbox.Xr-bbox.Xl, bbox.Yb-bbox.Yt)
+	if o.Epsilon == 0 && o.RelativeEpsilon == 0 {
+		return math.Max(defaultEpsilon, defaultRelativeEpsilon*size)
+	}
+	return math.Max(o.Epsilon, o.RelativeEpsilon*size)
+}
+
+func (o Options) validate() error {
+	if !isFinite(o.Epsilon) || o.Epsilon < 0 {
+		return fmt.Errorf("%w: Epsilon=%v", ErrInvalidTolerance, o.Epsilon)
+	}
+	if !isFinite(o.RelativeEpsilon) || o.RelativeEpsilon < 0 {
+		return fmt.Errorf("%w: RelativeEpsilon=%v", ErrInvalidTolerance, o.RelativeEpsilon)
+	}
+	return nil
+}
+
+// Build строит диаграмму так же, как CreateDiagram, но сначала проверяет входные
+// данные и вместо паники возвращает ошибку (ErrNoSites, ErrInvalidBoundingBox,
+// ErrInvalidTolerance или *SiteError).
+// Отброшенные дубликаты возвращаются в Diagram.Duplicates, их позиции в sites -
+// в Diagram.DuplicateIndices.
+//
+// Слайс sites не меняется. Diagram.Cells идут в порядке прохода прямой (по Y,
+// при равных Y - по X), ячейку sites[i] возвращает Diagram.CellOf(i)
+func Build(sites []Vertex, bbox BoundingBox, opts Options) (*Diagram, error) {
+	v := &Voronoi{cellsMap: make(map[Vertex]*Cell)}
+	return build(v, sites, bbox, opts)
+}
+
+// общая часть Build и Builder.Build: v должен быть подготовлен к запуску
+func build(v *Voronoi, sites []Vertex, bbox BoundingBox, opts Options) (d *Diagram, err error) {
+	if err := validateInput(sites, bbox); err != nil {
+		return nil, err
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	// внутренние инварианты алгоритма нарушаются через panic(*SiteError),
+	// превращаем их в ошибку
+	defer func() {
+		if r := recover(); r != nil {
+			siteErr, ok := r.(*SiteError)
+			if !ok {
+				panic(r)
+			}
+			d, err = nil, siteErr
+		}
+	}()
+
+	v.tracer = opts.Tracer
+	v.observer = opts.Observer
+	v.recordSteps = opts.RecordSteps
+	v.eps = opts.tolerance(bbox)
+	return v.run(sites, bbox, opts.CloseCells), nil
+}
+
+// Сайт с произвольными данными (например, ID станции) для BuildSites
+type Site struct {
+	Vertex
+	Data any
+}
+
+// BuildSites строит диаграмму как Build и прикрепляет к ячейкам данные сайтов:
+// у ячейки сайта sites[i] Index() == i и Data() == sites[i].Data.
+// У дубликатов ячейка одна - с данными первого вхождения
+func BuildSites(sites []Site, bbox BoundingBox, opts Options) (*Diagram, error) {
+	points := make([]Vertex, len(sites))
+	for i, site := range sites {
+		points[i] = site.Vertex
+	}
+	d, err := Build(points, bbox, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, cell := range d.Cells {
+		cell.data = sites[cell.index].Data
+	}
+	return d, nil
+}
+
+// проверка входных данных построения
+func validateInput(sites []Vertex, bbox BoundingBox) error {
+	if err := bbox.validate(); err != nil {
+		return err
+	}
+	if len(sites) == 0 {
+		return ErrNoSites
+	}
+	for i, site := range sites {
+		if !isFinite(site.X) || !isFinite(site.Y) {
+			return &SiteError{Index: i, Site: site, Err: ErrInvalidSite}
+		}
+		if !bbox.contains(site) {
+			return &SiteError{Index: i, Site: site, Err: ErrSiteOutOfBounds}
+		}
+	}
+	return nil
+}
+
+func (b BoundingBox) validate() error {
+	if !isFinite(b.Xl) || !isFinite(b.Xr) || !isFinite(b.Yt) || !isFinite(b.Yb) {
+		return fmt.Errorf("%w: %+v has NaN or Inf side", ErrInvalidBoundingBox, b)
+	}
+	if b.Xl >= b.Xr {
+		return fmt.Errorf("%w: Xl=%v >= Xr=%v", ErrInvalidBoundingBox, b.Xl, b.Xr)
+	}
+	if b.Yt >= b.Yb {
+		return fmt.Errorf("%w: Yt=%v >= Yb=%v", ErrInvalidBoundingBox, b.Yt, b.Yb)
+	}
+	return nil
+}
+
+func (b BoundingBox) contains(p Vertex) bool {
+	return p.X >= b.Xl && p.X <= b.Xr && p.Y >= b.Yt && p.Y <= b.Yb
+}
+
+func isFinite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
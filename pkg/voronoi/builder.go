@@ -0,0 +1,147 @@
+package voronoi
+
+// размер блока арены
+const arenaChunk = 256
+
+// Арена однотипных объектов. Объекты выделяются блоками, поэтому указатели на
+// них стабильны, а после reset память переиспользуется без новых аллокаций.
+// alloc не обнуляет объект - это делает вызывающий
+type arena[T any] struct {
+	chunks [][]T
+	chunk  int
+	next   int
+}
+
+func (a *arena[T]) alloc() *T {
+	if a.chunk == len(a.chunks) {
+		a.chunks = append(a.chunks, make([]T, arenaChunk))
+	}
+	p := &a.chunks[a.chunk][a.next]
+	a.next++
+	if a.next == arenaChunk {
+		a.chunk++
+		a.next = 0
+	}
+	return p
+}
+
+func (a *arena[T]) reset() {
+	a.chunk = 0
+	a.next = 0
+}
+
+// Пулы объектов алгоритма, которые Builder переиспользует между запусками
+type pools struct {
+	nodes     arena[rbtNode]
+	arcs      arena[BeachSection]
+	circles   arena[circleEvent]
+	cells     arena[Cell]
+	edges     arena[Edge]
+	halfEdges arena[HalfEdge]
+}
+
+func (p *pools) reset() {
+	p.nodes.reset()
+	p.arcs.reset()
+	p.circles.reset()
+	p.cells.reset()
+	p.edges.reset()
+	p.halfEdges.reset()
+}
+
+// Переиспользуемый построитель диаграмм. После первых запусков узлы дерева,
+// дуги, события круга, ячейки, ребра и слайсы берутся из пулов, и повторное
+// построение почти не выделяет память.
+//
+// Диаграмма, которую вернул Build, принадлежит построителю и действительна
+// только до следующего вызова Build или Reset. Builder не потокобезопасен
+type Builder struct {
+	v     Voronoi
+	pools pools
+}
+
+// NewBuilder создает построитель с пустыми пулами
+func NewBuilder() *Builder {
+	b := &Builder{}
+	b.v.cellsMap = make(map[Vertex]*Cell)
+	return b
+}
+
+// Reset возвращает все объекты прошлого построения в пулы
+func (b *Builder) Reset() {
+	b.pools.reset()
+
+	v := &b.v
+	clear(v.cellsMap)
+	clear(v.inputCells)
+	clear(v.cells)
+	clear(v.edges)
+	v.cells = v.cells[:0]
+	v.edges = v.edges[:0]
+	v.beachline = rbt{pool: &b.pools.nodes}
+	v.circleEvents = rbt{pool: &b.pools.nodes}
+	v.firstCircleEvent = nil
+	v.duplicates = v.duplicates[:0]
+	v.duplicateIndices = v.duplicateIndices[:0]
+	v.triangles = v.triangles[:0]
+	v.delaunayEdges = v.delaunayEdges[:0]
+	v.steps = nil
+	v.pools = &b.pools
+}
+
+// Build строит диаграмму так же, как функция Build, но на объектах из пулов
+func (b *Builder) Build(sites []Vertex, bbox BoundingBox, opts Options) (*Diagram, error) {
+	b.Reset()
+	return build(&b.v, sites, bbox, opts)
+}
+
+func (v *Voronoi) allocCell(site Vertex, id int) *Cell {
+	if v.pools == nil {
+		return newCell(site, id)
+	}
+	c := v.pools.cells.alloc()
+	// слайс полуребер оставляем, чтобы не выделять его заново
+	*c = Cell{site: site, id: id, index: -1, halfEdges: c.halfEdges[:0]}
+	return c
+}
+
+func (v *Voronoi) allocEdge(LeftCell, RightCell *Cell) *Edge {
+	if v.pools == nil {
+		return newEdge(LeftCell, RightCell)
+	}
+	e := v.pools.edges.alloc()
+	*e = Edge{
+		LeftCell:  LeftCell,
+		RightCell: RightCell,
+		Va:        EdgeVertex{NO_VERTEX, nil},
+		Vb:        EdgeVertex{NO_VERTEX, nil},
+	}
+	return e
+}
+
+func (v *Voronoi) allocHalfEdge(edge *Edge, LeftCell, RightCell *Cell) *HalfEdge {
+	if v.pools == nil {
+		return newHalfEdge(edge, LeftCell, RightCell)
+	}
+	h := v.pools.halfEdges.alloc()
+	initHalfEdge(h, edge, LeftCell, RightCell)
+	return h
+}
+
+func (v *Voronoi) allocArc(site Vertex) *BeachSection {
+	if v.pools == nil {
+		return &BeachSection{site: site}
+	}
+	a := v.pools.arcs.alloc()
+	*a = BeachSection{site: site}
+	return a
+}
+
+func (v *Voronoi) allocCircleEvent() *circleEvent {
+	if v.pools == nil {
+		return &circleEvent{}
+	}
+	c := v.pools.circles.alloc()
+	*c = circleEvent{}
+	return c
+}
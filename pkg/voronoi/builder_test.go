@@ -0,0 +1,49 @@
+package voronoi
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestBuilderMatchesBuild(t *testing.T) {
+	bbox := NewBoundingBox(0, 1000, 0, 1000)
+	r := rand.New(rand.NewSource(3))
+	b := NewBuilder()
+	for _, n := range []int{1000, 10, 500, 3000, 2, 700} {
+		sites := randomSites(r, n, bbox)
+		want := mustBuild(t, sites, bbox)
+		got, err := b.Build(sites, bbox, Options{CloseCells: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Cells) != len(want.Cells) || len(got.Edges) != len(want.Edges) {
+			t.Fatalf("n=%d: builder gave %d cells, %d edges; want %d, %d",
+				n, len(got.Cells), len(got.Edges), len(want.Cells), len(want.Edges))
+		}
+		for i := range want.Cells {
+			if !slices.Equal(got.Cells[i].Polygon(), want.Cells[i].Polygon()) {
+				t.Fatalf("n=%d: cell %d differs", n, i)
+			}
+		}
+		checkDiagram(t, got)
+	}
+}
+
+func BenchmarkBuilder(b *testing.B) {
+	bbox := NewBoundingBox(0, 1000, 0, 1000)
+	for _, n := range benchSizes() {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			sites := benchSites(n)
+			builder := NewBuilder()
+			opts := Options{CloseCells: true}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := builder.Build(sites, bbox, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -1,17 +1,80 @@
 package voronoi
 
-import "sort"
+import (
+	"cmp"
+	"slices"
+)
 
-type cell struct {
+// Ячейка диаграммы (область одного сайта)
+type Cell struct {
 	site      Vertex
-	halfEdges []*halfEdge
+	halfEdges []*HalfEdge
+	// позиция ячейки в Diagram.Cells
+	id int
+	// позиция сайта во входном слайсе (-1, если ее нет)
+	index int
+	// данные сайта из BuildSites
+	data any
+	// вес сайта в диаграмме мощности (0 для обычной диаграммы)
+	weight float64
 }
 
-func newCell(site Vertex) *cell {
-	return &cell{site: site}
+func newCell(site Vertex, id int) *Cell {
+	return &Cell{site: site, id: id, index: -1}
 }
 
-func (t *cell) prepare() int {
+// Site возвращает сайт (точку), которому принадлежит ячейка
+func (t *Cell) Site() Vertex {
+	return t.site
+}
+
+// Index возвращает позицию сайта во входном слайсе Build/BuildSites/BuildPower.
+// Для дубликатов ячейка одна - у первого вхождения. У ячеек DynamicDiagram -1
+func (t *Cell) Index() int {
+	return t.index
+}
+
+// Data возвращает данные сайта, переданные в BuildSites (иначе nil)
+func (t *Cell) Data() any {
+	return t.data
+}
+
+// Weight возвращает вес сайта (не 0 только в диаграмме мощности)
+func (t *Cell) Weight() float64 {
+	return t.weight
+}
+
+// HalfEdges возвращает полуребра ячейки, отсортированные по углу
+func (t *Cell) HalfEdges() []*HalfEdge {
+	return t.halfEdges
+}
+
+// Neighbors возвращает соседние ячейки (через общие ребра).
+// Граничные ребра bbox соседей не имеют и пропускаются.
+func (t *Cell) Neighbors() []*Cell {
+	neighbors := make([]*Cell, 0, len(t.halfEdges))
+	for _, he := range t.halfEdges {
+		edge := he.Edge
+		if edge.LeftCell == t && edge.RightCell != nil {
+			neighbors = append(neighbors, edge.RightCell)
+		} else if edge.RightCell == t && edge.LeftCell != nil {
+			neighbors = append(neighbors, edge.LeftCell)
+		}
+	}
+	return neighbors
+}
+
+// Polygon возвращает вершины ячейки в порядке обхода полуребер.
+// Полигон замкнут только если диаграмма строилась с closeCells
+func (t *Cell) Polygon() []Vertex {
+	polygon := make([]Vertex, 0, len(t.halfEdges))
+	for _, he := range t.halfEdges {
+		polygon = append(polygon, he.StartPoint())
+	}
+	return polygon
+}
+
+func (t *Cell) prepare() int {
 	halfedges := t.halfEdges
 	iHalfedge := len(halfedges) - 1
 
@@ -24,7 +87,8 @@ func (t *cell) prepare() int {
 		}
 	}
 
-	sort.Sort(halfEdgesByAngle{halfedges})
+	// по убыванию угла
+	slices.SortFunc(halfedges, func(a, b *HalfEdge) int { return cmp.Compare(b.Angle, a.Angle) })
 	t.halfEdges = halfedges
 	return len(halfedges)
 }
@@ -0,0 +1,219 @@
+package voronoi
+
+import "fmt"
+
+// Диаграмма в виде списка ребер с двойными связями (DCEL). Каждое ребро
+// диаграммы дает два полуребра-близнеца; полуребра одной грани связаны в
+// цикл через Next/Prev. Граничные ребра bbox отделяют ячейки от внешней грани
+type DCEL struct {
+	Vertices  []*DCELVertex
+	HalfEdges []*DCELHalfEdge
+	// Faces[i] - грань ячейки Diagram.Cells[i]
+	Faces []*DCELFace
+	// Внешняя грань (за пределами bbox), Cell у нее nil
+	Outer *DCELFace
+}
+
+// Вершина DCEL. ID - позиция в DCEL.Vertices
+type DCELVertex struct {
+	ID    int
+	Point Vertex
+	// Одно из полуребер, выходящих из вершины
+	Incident *DCELHalfEdge
+}
+
+// Полуребро DCEL. ID - позиция в DCEL.HalfEdges
+type DCELHalfEdge struct {
+	ID     int
+	Origin *DCELVertex
+	Twin   *DCELHalfEdge
+	Next   *DCELHalfEdge
+	Prev   *DCELHalfEdge
+	// Грань, которую полуребро обходит
+	Face *DCELFace
+	// Ребро диаграммы, которому принадлежит полуребро
+	Edge *Edge
+}
+
+// Грань DCEL. ID - позиция в DCEL.Faces (у внешней грани - len(DCEL.Faces))
+type DCELFace struct {
+	ID   int
+	Cell *Cell
+	// Одно из полуребер границы грани (nil у ячейки без ребер)
+	Boundary *DCELHalfEdge
+}
+
+// Dest возвращает конец полуребра
+func (h *DCELHalfEdge) Dest() *DCELVertex {
+	return h.Twin.Origin
+}
+
+// HalfEdges возвращает полуребра границы грани по циклу Next
+func (f *DCELFace) HalfEdges() []*DCELHalfEdge {
+	var ret []*DCELHalfEdge
+	if f.Boundary == nil {
+		return ret
+	}
+	h := f.Boundary
+	for {
+		ret = append(ret, h)
+		h = h.Next
+		if h == f.Boundary {
+			return ret
+		}
+	}
+}
+
+// Outgoing возвращает полуребра, выходящие из вершины, в порядке обхода вокруг нее
+func (v *DCELVertex) Outgoing() []*DCELHalfEdge {
+	var ret []*DCELHalfEdge
+	h := v.Incident
+	for {
+		ret = append(ret, h)
+		h = h.Prev.Twin
+		if h == v.Incident {
+			return ret
+		}
+	}
+}
+
+// DCEL строит DCEL по диаграмме с замкнутыми ячейками (CloseCells).
+// Если полуребра какой-то ячейки не образуют цикл, возвращается ErrOpenCells
+func (d *Diagram) DCEL() (*DCEL, error) {
+	dcel := &DCEL{
+		Faces: make([]*DCELFace, len(d.Cells)),
+		Outer: &DCELFace{ID: len(d.Cells)},
+	}
+
+	ids := make(map[Vertex]*DCELVertex)
+	vertex := func(p Vertex) *DCELVertex {
+		v, ok := ids[p]
+		if !ok {
+			v = &DCELVertex{ID: len(dcel.Vertices), Point: p}
+			ids[p] = v
+			dcel.Vertices = append(dcel.Vertices, v)
+		}
+		return v
+	}
+	newHalfEdge := func(origin *DCELVertex, face *DCELFace, edge *Edge) *DCELHalfEdge {
+		h := &DCELHalfEdge{ID: len(dcel.HalfEdges), Origin: origin, Face: face, Edge: edge}
+		dcel.HalfEdges = append(dcel.HalfEdges, h)
+		if origin.Incident == nil {
+			origin.Incident = h
+		}
+		return h
+	}
+
+	// первое полуребро каждого ребра ждет близнеца из соседней ячейки
+	pending := make(map[*Edge]*DCELHalfEdge, len(d.Edges))
+	// полуребра внешней грани по началу
+	outer := make(map[*DCELVertex]*DCELHalfEdge)
+
+	for i, cell := range d.Cells {
+		face := &DCELFace{ID: i, Cell: cell}
+		dcel.Faces[i] = face
+
+		var first, prev *DCELHalfEdge
+		for k, he := range cell.halfEdges {
+			next := cell.halfEdges[(k+1)%len(cell.halfEdges)]
+			if he.EndPoint() != next.StartPoint() {
+				return nil, fmt.Errorf("%w: cell %d breaks at %v", ErrOpenCells, i, he.EndPoint())
+			}
+
+			h := newHalfEdge(vertex(he.StartPoint()), face, he.Edge)
+			if prev != nil {
+				prev.Next, h.Prev = h, prev
+			} else {
+				first = h
+			}
+			prev = h
+
+			if he.Edge.RightCell == nil {
+				twin := newHalfEdge(vertex(he.EndPoint()), dcel.Outer, he.Edge)
+				h.Twin, twin.Twin = twin, h
+				outer[twin.Origin] = twin
+			} else if twin := pending[he.Edge]; twin != nil {
+				h.Twin, twin.Twin = twin, h
+				delete(pending, he.Edge)
+			} else {
+				pending[he.Edge] = h
+			}
+		}
+		if first != nil {
+			prev.Next, first.Prev = first, prev
+			face.Boundary = first
+		}
+	}
+	if len(pending) > 0 {
+		return nil, fmt.Errorf("%w: %d edges have a single side", ErrOpenCells, len(pending))
+	}
+
+	// внешняя грань: за полуребром идет то, что начинается в его конце
+	for _, h := range outer {
+		next := outer[h.Dest()]
+		if next == nil {
+			return nil, fmt.Errorf("%w: border breaks at %v", ErrOpenCells, h.Dest().Point)
+		}
+		h.Next, next.Prev = next, h
+		if dcel.Outer.Boundary == nil || h.ID < dcel.Outer.Boundary.ID {
+			dcel.Outer.Boundary = h
+		}
+	}
+	return dcel, nil
+}
+
+// Validate проверяет связи DCEL: близнецы взаимны, Next и Prev обратны друг
+// другу, полуребро кончается там, где начинается следующее, у следующего та
+// же грань, а обход вокруг каждой вершины возвращается в исходное полуребро
+func (dcel *DCEL) Validate() error {
+	for i, h := range dcel.HalfEdges {
+		switch {
+		case h.ID != i:
+			return fmt.Errorf("voronoi: dcel: half-edge %d has ID %d", i, h.ID)
+		case h.Twin == nil || h.Twin.Twin != h || h.Twin == h:
+			return fmt.Errorf("voronoi: dcel: half-edge %d: bad twin", i)
+		case h.Next == nil || h.Next.Prev != h || h.Prev == nil || h.Prev.Next != h:
+			return fmt.Errorf("voronoi: dcel: half-edge %d: bad next/prev", i)
+		case h.Next.Origin != h.Dest():
+			return fmt.Errorf("voronoi: dcel: half-edge %d: next starts at %v, not at %v", i, h.Next.Origin.Point, h.Dest().Point)
+		case h.Next.Face != h.Face:
+			return fmt.Errorf("voronoi: dcel: half-edge %d: next is on another face", i)
+		case h.Origin == h.Dest():
+			return fmt.Errorf("voronoi: dcel: half-edge %d has zero length", i)
+		}
+	}
+
+	for i, v := range dcel.Vertices {
+		if v.ID != i || v.Incident == nil || v.Incident.Origin != v {
+			return fmt.Errorf("voronoi: dcel: vertex %d: bad incident half-edge", i)
+		}
+		// вокруг вершины не может быть больше полуребер, чем всего
+		h := v.Incident
+		for n := 0; ; n++ {
+			if h.Origin != v || n > len(dcel.HalfEdges) {
+				return fmt.Errorf("voronoi: dcel: vertex %d: rotation does not close", i)
+			}
+			h = h.Prev.Twin
+			if h == v.Incident {
+				break
+			}
+		}
+	}
+
+	faces := append(dcel.Faces[:len(dcel.Faces):len(dcel.Faces)], dcel.Outer)
+	seen := 0
+	for _, f := range faces {
+		if f.Boundary == nil {
+			continue
+		}
+		if f.Boundary.Face != f {
+			return fmt.Errorf("voronoi: dcel: face %d: boundary is on another face", f.ID)
+		}
+		seen += len(f.HalfEdges())
+	}
+	// каждое полуребро лежит ровно в одном цикле грани
+	if seen != len(dcel.HalfEdges) {
+		return fmt.Errorf("voronoi: dcel: face cycles cover %d of %d half-edges", seen, len(dcel.HalfEdges))
+	}
+	return nil
+}
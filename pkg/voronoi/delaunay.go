@@ -0,0 +1,54 @@
+package voronoi
+
+// Триангуляция Делоне - граф, двойственный диаграмме Вороного.
+// Все индексы указывают на Diagram.Cells
+type Triangulation struct {
+	// Треугольники, вершины упорядочены против часовой стрелки (при оси Y вверх)
+	Triangles [][3]int
+	// Ребра без повторов, i < j
+	Edges [][2]int
+}
+
+// Delaunay возвращает триангуляцию Делоне, собранную во время прохода прямой сканирования.
+// Смежность берется до обрезки по bbox, поэтому ребра Вороного,
+// ушедшие за пределы bbox, тоже дают ребра Делоне
+func (d *Diagram) Delaunay() *Triangulation {
+	t := &Triangulation{
+		Triangles: make([][3]int, len(d.triangles)),
+	}
+	copy(t.Triangles, d.triangles)
+
+	seen := make(map[[2]int]struct{}, len(d.delaunayEdges))
+	addEdge := func(i, j int) {
+		if i > j {
+			i, j = j, i
+		}
+		key := [2]int{i, j}
+		if _, ok := seen[key]; ok {
+			return
+		}
+		seen[key] = struct{}{}
+		t.Edges = append(t.Edges, key)
+	}
+
+	for _, e := range d.delaunayEdges {
+		addEdge(e[0], e[1])
+	}
+	// при вырожденных (коциркулярных) входах веер добавляет диагонали,
+	// которых нет среди ребер Вороного
+	for _, tr := range t.Triangles {
+		addEdge(tr[0], tr[1])
+		addEdge(tr[1], tr[2])
+		addEdge(tr[2], tr[0])
+	}
+	return t
+}
+
+func (v *Voronoi) addTriangle(a, b, c *Cell) {
+	pa, pb, pc := a.site, b.site, c.site
+	// ориентируем против часовой стрелки
+	if (pb.X-pa.X)*(pc.Y-pa.Y)-(pb.Y-pa.Y)*(pc.X-pa.X) < 0 {
+		b, c = c, b
+	}
+	v.triangles = append(v.triangles, [3]int{a.id, b.id, c.id})
+}
@@ -0,0 +1,231 @@
+package voronoi
+
+import (
+	"math"
+	"slices"
+)
+
+// Диаграмма, в которую сайты добавляются и из которой удаляются по одному.
+// Insert и Remove пересчитывают только затронутые ячейки: новую ячейку и ее
+// соседей при вставке, соседей удаленной ячейки при удалении.
+//
+// Каждая ячейка хранится как bbox, отсеченный биссектрисами соседей (как в
+// BuildPower), поэтому ячейки всегда замкнуты по bbox. Сайты-дубликаты не
+// допускаются. DynamicDiagram не потокобезопасен
+type DynamicDiagram struct {
+	bbox       BoundingBox
+	eps        float64
+	closeCells bool
+
+	// сайты по внутренним номерам; у свободных номеров многоугольник nil
+	sites []Vertex
+	// многоугольники ячеек, метки ребер - номера соседних сайтов
+	polygons [][]powerPoint
+	ids      map[Vertex]int
+	// свободные номера удаленных сайтов
+	free []int
+}
+
+// NewDynamicDiagram создает пустую диаграмму в bbox. Из opts используются
+// CloseCells (для Diagram) и допуски; Tracer, Observer и RecordSteps игнорируются
+func NewDynamicDiagram(bbox BoundingBox, opts Options) (*DynamicDiagram, error) {
+	if err := bbox.validate(); err != nil {
+		return nil, err
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	return &DynamicDiagram{
+		bbox:       bbox,
+		eps:        opts.tolerance(bbox),
+		closeCells: opts.CloseCells,
+		ids:        make(map[Vertex]int),
+	}, nil
+}
+
+// Len возвращает число сайтов
+func (d *DynamicDiagram) Len() int {
+	return len(d.ids)
+}
+
+// Insert добавляет сайт. Ошибка - *SiteError с ErrInvalidSite,
+// ErrSiteOutOfBounds или ErrDuplicateSite (Index у нее всегда -1)
+func (d *DynamicDiagram) Insert(site Vertex) error {
+	if !isFinite(site.X) || !isFinite(site.Y) {
+		return &SiteError{Index: -1, Site: site, Err: ErrInvalidSite}
+	}
+	if !d.bbox.contains(site) {
+		return &SiteError{Index: -1, Site: site, Err: ErrSiteOutOfBounds}
+	}
+	if _, ok := d.ids[site]; ok {
+		return &SiteError{Index: -1, Site: site, Err: ErrDuplicateSite}
+	}
+
+	// соседи новой ячейки - сайты, ячейки которых теряют часть площади.
+	// Они связны по смежности ячеек, поэтому ищем их обходом от ближайшего сайта
+	var affected []int
+	if len(d.ids) > 0 {
+		nearest := d.nearest(site)
+		affected = append(affected, nearest)
+		visited := map[int]bool{nearest: true}
+		for k := 0; k < len(affected); k++ {
+			for _, p := range d.polygons[affected[k]] {
+				j := p.edge
+				if j == borderLabel || visited[j] {
+					continue
+				}
+				visited[j] = true
+				if d.loses(j, site) {
+					affected = append(affected, j)
+				}
+			}
+		}
+	}
+
+	id := d.alloc(site)
+	polygon := bboxPolygon(d.bbox)
+	for _, j := range affected {
+		polygon = clipBisector(polygon, site, d.sites[j], j, d.eps)
+		d.polygons[j] = clipBisector(d.polygons[j], d.sites[j], site, id, d.eps)
+	}
+	d.polygons[id] = polygon
+	return nil
+}
+
+// Remove удаляет сайт. Если его нет - *SiteError с ErrSiteNotFound
+func (d *DynamicDiagram) Remove(site Vertex) error {
+	id, ok := d.ids[site]
+	if !ok {
+		return &SiteError{Index: -1, Site: site, Err: ErrSiteNotFound}
+	}
+
+	// новые соседи бывшего соседа - его прежние соседи и соседи удаленной ячейки
+	neighbors := polygonNeighbors(d.polygons[id], nil)
+	for _, i := range neighbors {
+		candidates := polygonNeighbors(d.polygons[i], slices.Clone(neighbors))
+		polygon := bboxPolygon(d.bbox)
+		for _, j := range candidates {
+			if j == id || j == i {
+				continue
+			}
+			polygon = clipBisector(polygon, d.sites[i], d.sites[j], j, d.eps)
+		}
+		d.polygons[i] = polygon
+	}
+
+	delete(d.ids, site)
+	d.polygons[id] = nil
+	d.free = append(d.free, id)
+	return nil
+}
+
+// Diagram собирает текущую диаграмму. Ячейки идут в порядке внутренних номеров
+// сайтов, а не в порядке вставки. Delaunay() возвращает только ребра
+func (d *DynamicDiagram) Diagram() *Diagram {
+	v := &Voronoi{eps: d.eps}
+	index := make([]int, len(d.sites))
+	var order []int
+	for id, polygon := range d.polygons {
+		if polygon == nil {
+			continue
+		}
+		index[id] = len(v.cells)
+		v.cells = append(v.cells, newCell(d.sites[id], len(v.cells)))
+		order = append(order, id)
+	}
+
+	shared := make(map[[2]int]*Edge)
+	for i, id := range order {
+		polygon := slices.Clone(d.polygons[id])
+		for k := range polygon {
+			if polygon[k].edge != borderLabel {
+				polygon[k].edge = index[polygon[k].edge]
+			}
+		}
+		v.addPolygon(shared, i, polygon, d.closeCells)
+	}
+	// соседние ячейки считаются отдельно, и их общие вершины расходятся в последних битах
+	v.weldVertices()
+
+	return &Diagram{
+		Cells:         v.cells,
+		Edges:         v.edges,
+		BBox:          d.bbox,
+		delaunayEdges: v.delaunayEdges,
+		eps:           d.eps,
+	}
+}
+
+// выделяет номер под новый сайт
+func (d *DynamicDiagram) alloc(site Vertex) int {
+	var id int
+	if n := len(d.free); n > 0 {
+		id = d.free[n-1]
+		d.free = d.free[:n-1]
+		d.sites[id] = site
+	} else {
+		id = len(d.sites)
+		d.sites = append(d.sites, site)
+		d.polygons = append(d.polygons, nil)
+	}
+	d.ids[site] = id
+	return id
+}
+
+// ближайший к p сайт. Ячейка - это bbox, отсеченный полуплоскостями соседей,
+// поэтому если p вне ячейки, один из соседей строго ближе к p. Переходим к
+// ближайшему соседу, пока такой есть
+func (d *DynamicDiagram) nearest(p Vertex) int {
+	id := -1
+	for _, i := range d.ids {
+		id = i
+		break
+	}
+	for {
+		next := -1
+		best := sqDist(p, d.sites[id])
+		for _, q := range d.polygons[id] {
+			if q.edge == borderLabel {
+				continue
+			}
+			if dist := sqDist(p, d.sites[q.edge]); dist < best {
+				next, best = q.edge, dist
+			}
+		}
+		if next < 0 {
+			return id
+		}
+		id = next
+	}
+}
+
+// теряет ли ячейка id площадь при вставке сайта p: ячейка выпуклая,
+// поэтому достаточно проверить ее вершины
+func (d *DynamicDiagram) loses(id int, p Vertex) bool {
+	site := d.sites[id]
+	for _, q := range d.polygons[id] {
+		if math.Sqrt(sqDist(q.Vertex, p))+d.eps < math.Sqrt(sqDist(q.Vertex, site)) {
+			return true
+		}
+	}
+	return false
+}
+
+// дописывает к ids номера соседей по ребрам многоугольника (без повторов)
+func polygonNeighbors(polygon []powerPoint, ids []int) []int {
+	for _, p := range polygon {
+		if p.edge != borderLabel && !slices.Contains(ids, p.edge) {
+			ids = append(ids, p.edge)
+		}
+	}
+	return ids
+}
+
+// отсекает от многоугольника ячейки сайта p точки, которые ближе к q.
+// Новое ребро помечается label
+func clipBisector(polygon []powerPoint, p, q Vertex, label int, eps float64) []powerPoint {
+	a := 2 * (q.X - p.X)
+	b := 2 * (q.Y - p.Y)
+	c := q.X*q.X + q.Y*q.Y - p.X*p.X - p.Y*p.Y
+	return clipPolygon(polygon, a, b, c, label, eps)
+}
@@ -0,0 +1,132 @@
+package voronoi
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// ячейки с одинаковыми сайтами должны совпадать как многоугольники
+// (с точностью до начальной вершины) и иметь тех же соседей
+func checkSameCells(t *testing.T, got, want *Diagram) {
+	t.Helper()
+	if len(got.Cells) != len(want.Cells) {
+		t.Fatalf("got %d cells, want %d", len(got.Cells), len(want.Cells))
+	}
+	scale := math.Max(want.BBox.Xr-want.BBox.Xl, want.BBox.Yb-want.BBox.Yt)
+	tol := testEps * scale
+
+	cells := make(map[Vertex]*Cell, len(want.Cells))
+	for _, cell := range want.Cells {
+		cells[cell.Site()] = cell
+	}
+	for _, cell := range got.Cells {
+		w := cells[cell.Site()]
+		if w == nil {
+			t.Fatalf("unexpected site %v", cell.Site())
+		}
+		gp, wp := cell.Polygon(), w.Polygon()
+		if len(gp) != len(wp) {
+			t.Fatalf("site %v: polygon %v, want %v", cell.Site(), gp, wp)
+		}
+		for _, p := range gp {
+			if !hasVertexNear(wp, p, tol) {
+				t.Fatalf("site %v: polygon %v, want %v", cell.Site(), gp, wp)
+			}
+		}
+
+		neighbors := make(map[Vertex]bool)
+		for _, n := range w.Neighbors() {
+			neighbors[n.Site()] = true
+		}
+		gn := cell.Neighbors()
+		if len(gn) != len(neighbors) {
+			t.Fatalf("site %v: %d neighbors, want %d", cell.Site(), len(gn), len(neighbors))
+		}
+		for _, n := range gn {
+			if !neighbors[n.Site()] {
+				t.Fatalf("site %v: unexpected neighbor %v", cell.Site(), n.Site())
+			}
+		}
+	}
+}
+
+func hasVertexNear(polygon []Vertex, p Vertex, tol float64) bool {
+	for _, q := range polygon {
+		if math.Abs(q.X-p.X) <= tol && math.Abs(q.Y-p.Y) <= tol {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDynamicMatchesBuild(t *testing.T) {
+	r := rand.New(rand.NewSource(6))
+	bbox := NewBoundingBox(0, 1000, 0, 500)
+	d, err := NewDynamicDiagram(bbox, Options{CloseCells: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sites []Vertex
+	for step := 0; step < 600; step++ {
+		if len(sites) > 0 && r.Intn(3) == 0 {
+			k := r.Intn(len(sites))
+			if err := d.Remove(sites[k]); err != nil {
+				t.Fatal(err)
+			}
+			sites[k] = sites[len(sites)-1]
+			sites = sites[:len(sites)-1]
+		} else {
+			site := randomSites(r, 1, bbox)[0]
+			if err := d.Insert(site); err != nil {
+				t.Fatal(err)
+			}
+			sites = append(sites, site)
+		}
+		if d.Len() != len(sites) {
+			t.Fatalf("step %d: Len %d, want %d", step, d.Len(), len(sites))
+		}
+		if len(sites) == 0 || step%10 != 0 {
+			continue
+		}
+
+		got := d.Diagram()
+		checkDiagram(t, got)
+		dcel, err := got.DCEL()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := dcel.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		checkSameCells(t, got, mustBuild(t, sites, bbox))
+	}
+}
+
+func TestDynamicErrors(t *testing.T) {
+	bbox := NewBoundingBox(0, 10, 0, 10)
+	d, err := NewDynamicDiagram(bbox, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Insert(Vertex{1, 1}); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name string
+		err  error
+		got  error
+	}{
+		{"duplicate", ErrDuplicateSite, d.Insert(Vertex{1, 1})},
+		{"outside", ErrSiteOutOfBounds, d.Insert(Vertex{11, 1})},
+		{"nan", ErrInvalidSite, d.Insert(Vertex{math.NaN(), 1})},
+		{"missing", ErrSiteNotFound, d.Remove(Vertex{2, 2})},
+	}
+	for _, c := range cases {
+		if !errors.Is(c.got, c.err) {
+			t.Errorf("%s: got %v, want %v", c.name, c.got, c.err)
+		}
+	}
+}
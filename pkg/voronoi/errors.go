@@ -0,0 +1,54 @@
+package voronoi
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// Нет ни одного сайта
+	ErrNoSites = errors.New("voronoi: no sites")
+	// Bbox пустой, перевернутый (Xl >= Xr или Yt >= Yb) или содержит NaN/Inf
+	ErrInvalidBoundingBox = errors.New("voronoi: invalid bounding box")
+	// У сайта координата NaN или Inf
+	ErrInvalidSite = errors.New("voronoi: site coordinate is NaN or Inf")
+	// У взвешенного сайта вес NaN или Inf
+	ErrInvalidWeight = errors.New("voronoi: site weight is NaN or Inf")
+	// Сайт лежит за пределами bbox
+	ErrSiteOutOfBounds = errors.New("voronoi: site is outside bounding box")
+	// Область обрезки: контур короче трех точек или с NaN/Inf
+	ErrInvalidRegion = errors.New("voronoi: invalid region")
+	// Options.Epsilon или Options.RelativeEpsilon отрицательный, NaN или Inf
+	ErrInvalidTolerance = errors.New("voronoi: invalid tolerance")
+	// DynamicDiagram.Insert: сайт с такими координатами уже есть
+	ErrDuplicateSite = errors.New("voronoi: duplicate site")
+	// DynamicDiagram.Remove: сайта с такими координатами нет
+	ErrSiteNotFound = errors.New("voronoi: site not found")
+	// Diagram.DCEL: ячейки не замкнуты (диаграмма строилась без CloseCells)
+	ErrOpenCells = errors.New("voronoi: cells are not closed")
+	// ReadGeoJSONSites: некорректный или неподдерживаемый GeoJSON
+	ErrGeoJSON = errors.New("voronoi: bad geojson")
+	// Внутренняя ошибка: для сайта не нашлось ячейки
+	ErrCellNotFound = errors.New("voronoi: couldn't find cell for site")
+	// Внутренняя ошибка: ячейку не удалось замкнуть по bbox
+	ErrCellNotClosed = errors.New("voronoi: couldn't close cell")
+)
+
+// Ошибка, связанная с конкретным сайтом.
+// Index - позиция сайта во входном слайсе (-1, если неизвестна)
+type SiteError struct {
+	Index int
+	Site  Vertex
+	Err   error
+}
+
+func (e *SiteError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("%v: %v", e.Err, e.Site)
+	}
+	return fmt.Sprintf("%v: sites[%d] = %v", e.Err, e.Index, e.Site)
+}
+
+func (e *SiteError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,288 @@
+package voronoi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// диаграммы для проверки экспорта: эталонные случаи и случайные сайты
+func exportDiagrams(t *testing.T) []*Diagram {
+	t.Helper()
+	var ds []*Diagram
+	for _, c := range goldenCases {
+		ds = append(ds, mustBuild(t, c.sites, c.bbox))
+	}
+	bbox := NewBoundingBox(0, 1000, 0, 500)
+	ds = append(ds, mustBuild(t, randomSites(rand.New(rand.NewSource(20)), 300, bbox), bbox))
+	return ds
+}
+
+// кольцо совпадает с многоугольником ячейки с точностью до начальной точки и направления обхода
+func sameRing(ring, polygon []Vertex) bool {
+	if len(ring) != len(polygon) {
+		return false
+	}
+	start := slices.Index(ring, polygon[0])
+	if start < 0 {
+		return false
+	}
+	rotated := append(slices.Clone(ring[start:]), ring[:start]...)
+	if slices.Equal(rotated, polygon) {
+		return true
+	}
+	slices.Reverse(rotated[1:])
+	return slices.Equal(rotated, polygon)
+}
+
+func TestGeoJSONExport(t *testing.T) {
+	for _, d := range exportDiagrams(t) {
+		var buf bytes.Buffer
+		if err := d.WriteGeoJSON(&buf); err != nil {
+			t.Fatal(err)
+		}
+		var fc struct {
+			Type     string `json:"type"`
+			Features []struct {
+				Geometry struct {
+					Type        string          `json:"type"`
+					Coordinates json.RawMessage `json:"coordinates"`
+				} `json:"geometry"`
+				Properties map[string]any `json:"properties"`
+			} `json:"features"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &fc); err != nil {
+			t.Fatal(err)
+		}
+		if fc.Type != "FeatureCollection" {
+			t.Fatalf("type %q", fc.Type)
+		}
+
+		kinds := make(map[string]int)
+		for _, f := range fc.Features {
+			kind := f.Properties["kind"].(string)
+			kinds[kind]++
+			if kind != "cell" {
+				continue
+			}
+			var rings [][][2]float64
+			if err := json.Unmarshal(f.Geometry.Coordinates, &rings); err != nil || f.Geometry.Type != "Polygon" || len(rings) != 1 {
+				t.Fatalf("cell geometry %s %s", f.Geometry.Type, f.Geometry.Coordinates)
+			}
+			ring := make([]Vertex, len(rings[0]))
+			for i, c := range rings[0] {
+				ring[i] = Vertex{c[0], c[1]}
+			}
+			if ring[0] != ring[len(ring)-1] {
+				t.Fatalf("ring is not closed: %v", ring)
+			}
+			ring = ring[:len(ring)-1]
+			if polygonSignedArea(ring) <= 0 {
+				t.Fatalf("ring is not counterclockwise: %v", ring)
+			}
+			cell := d.Cells[int(f.Properties["index"].(float64))]
+			if !sameRing(ring, cell.Polygon()) {
+				t.Fatalf("ring %v differs from cell polygon %v", ring, cell.Polygon())
+			}
+		}
+		if kinds["cell"] != len(d.Cells) || kinds["site"] != len(d.Cells) || kinds["edge"] != len(d.Edges) {
+			t.Fatalf("features %v for %d cells and %d edges", kinds, len(d.Cells), len(d.Edges))
+		}
+
+		// точки сайтов читаются обратно
+		coords := make([][2]float64, 0, len(d.Cells))
+		for _, cell := range d.Cells {
+			coords = append(coords, [2]float64{cell.site.X, cell.site.Y})
+		}
+		data, err := json.Marshal(Geometry{Type: "MultiPoint", Coordinates: coords})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadGeoJSONSites(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, cell := range d.Cells {
+			if got[i] != cell.site {
+				t.Fatalf("site %d: read %v, want %v", i, got[i], cell.site)
+			}
+		}
+	}
+
+	_, err := ReadGeoJSONSites(strings.NewReader(`{"type":"LineString","coordinates":[[0,0],[1,1]]}`))
+	if !errors.Is(err, ErrGeoJSON) {
+		t.Fatalf("want ErrGeoJSON, got %v", err)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, d := range exportDiagrams(t) {
+		want := d.JSON()
+		data, err := json.Marshal(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got DiagramJSON
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(&got, want) {
+			t.Fatalf("round trip differs:\n%s", data)
+		}
+
+		for i, e := range got.Edges {
+			edge := d.Edges[i]
+			a, b := got.Vertices[e.A], got.Vertices[e.B]
+			if (Vertex{a[0], a[1]}) != edge.Va.Vertex || (Vertex{b[0], b[1]}) != edge.Vb.Vertex {
+				t.Fatalf("edge %d: %v-%v, want %v-%v", i, a, b, edge.Va.Vertex, edge.Vb.Vertex)
+			}
+		}
+		for i, c := range got.Cells {
+			polygon := d.Cells[i].Polygon()
+			if len(c.Polygon) != len(polygon) {
+				t.Fatalf("cell %d: %d points, want %d", i, len(c.Polygon), len(polygon))
+			}
+			for k, p := range c.Polygon {
+				if (Vertex{p[0], p[1]}) != polygon[k] {
+					t.Fatalf("cell %d point %d: %v, want %v", i, k, p, polygon[k])
+				}
+			}
+		}
+	}
+}
+
+func TestWKTExport(t *testing.T) {
+	for _, d := range exportDiagrams(t) {
+		var buf bytes.Buffer
+		if err := d.WriteWKT(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		var lines []string
+		sc := bufio.NewScanner(&buf)
+		sc.Buffer(nil, 1<<20)
+		for sc.Scan() {
+			lines = append(lines, sc.Text())
+		}
+		if len(lines) != len(d.Cells) {
+			t.Fatalf("%d lines for %d cells", len(lines), len(d.Cells))
+		}
+
+		for i, line := range lines {
+			body, ok := strings.CutPrefix(line, "POLYGON ((")
+			body, ok2 := strings.CutSuffix(body, "))")
+			if !ok || !ok2 {
+				t.Fatalf("line %d: %q", i, line)
+			}
+			var ring []Vertex
+			for _, pair := range strings.Split(body, ", ") {
+				xy := strings.Fields(pair)
+				if len(xy) != 2 {
+					t.Fatalf("line %d: bad point %q", i, pair)
+				}
+				x, errX := strconv.ParseFloat(xy[0], 64)
+				y, errY := strconv.ParseFloat(xy[1], 64)
+				if errX != nil || errY != nil {
+					t.Fatalf("line %d: bad point %q", i, pair)
+				}
+				ring = append(ring, Vertex{x, y})
+			}
+			if ring[0] != ring[len(ring)-1] {
+				t.Fatalf("line %d: ring is not closed", i)
+			}
+			if !slices.Equal(ring[:len(ring)-1], d.Cells[i].Polygon()) {
+				t.Fatalf("line %d: ring %v differs from cell polygon %v", i, ring, d.Cells[i].Polygon())
+			}
+		}
+	}
+}
+
+// разбор SVG из WriteSVG
+type svgDoc struct {
+	Width   int    `xml:"width,attr"`
+	Height  int    `xml:"height,attr"`
+	ViewBox string `xml:"viewBox,attr"`
+	Groups  []struct {
+		Polygons []struct {
+			Points string `xml:"points,attr"`
+			Fill   string `xml:"fill,attr"`
+		} `xml:"polygon"`
+		Lines   []struct{} `xml:"line"`
+		Circles []struct {
+			CX float64 `xml:"cx,attr"`
+			CY float64 `xml:"cy,attr"`
+		} `xml:"circle"`
+	} `xml:"g"`
+	Rects []struct{} `xml:"rect"`
+}
+
+func TestSVGStructure(t *testing.T) {
+	for _, d := range exportDiagrams(t) {
+		for _, fill := range []bool{false, true} {
+			var buf bytes.Buffer
+			if err := d.WriteSVG(&buf, SVGOptions{Width: 640, Height: 480, FillCells: fill}); err != nil {
+				t.Fatal(err)
+			}
+			var doc svgDoc
+			if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+				t.Fatalf("invalid svg: %v", err)
+			}
+
+			b := d.BBox
+			viewBox := fmt.Sprintf("%g %g %g %g", b.Xl, b.Yt, b.Xr-b.Xl, b.Yb-b.Yt)
+			if doc.Width != 640 || doc.Height != 480 || doc.ViewBox != viewBox {
+				t.Fatalf("size %dx%d viewBox %q, want 640x480 %q", doc.Width, doc.Height, doc.ViewBox, viewBox)
+			}
+			if len(doc.Rects) != 1 {
+				t.Fatalf("%d bbox frames", len(doc.Rects))
+			}
+
+			var polygons, lines, circles int
+			for _, g := range doc.Groups {
+				polygons += len(g.Polygons)
+				lines += len(g.Lines)
+				circles += len(g.Circles)
+				for i, p := range g.Polygons {
+					// по многоугольнику на ячейку в порядке Diagram.Cells
+					polygon := d.Cells[i].Polygon()
+					var points []string
+					for _, v := range polygon {
+						points = append(points, fmt.Sprintf("%g,%g", v.X, v.Y))
+					}
+					if p.Points != strings.Join(points, " ") || p.Fill != DefaultPalette[i%len(DefaultPalette)] {
+						t.Fatalf("cell %d: polygon %q fill %s", i, p.Points, p.Fill)
+					}
+				}
+				for i, c := range g.Circles {
+					if (Vertex{c.CX, c.CY}) != d.Cells[i].site {
+						t.Fatalf("site %d: circle at %v, %v", i, c.CX, c.CY)
+					}
+				}
+			}
+
+			wantPolygons := 0
+			if fill {
+				wantPolygons = len(d.Cells)
+			}
+			inner := 0
+			for _, e := range d.Edges {
+				if !e.IsBorder() {
+					inner++
+				}
+			}
+			if polygons != wantPolygons || lines != inner || circles != len(d.Cells) {
+				t.Fatalf("fill=%v: %d polygons, %d lines, %d circles; want %d, %d, %d",
+					fill, polygons, lines, circles, wantPolygons, inner, len(d.Cells))
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package voronoi
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// Сайты из байтов фаззера. В режиме grid каждая координата - один байт,
+// то есть сайты лежат на сетке 256x256: так фаззер легко находит
+// дубликаты, коллинеарные и коцикличные наборы. Иначе координата - uint32,
+// растянутый на bbox
+func fuzzSites(data []byte, grid bool, bbox BoundingBox) []Vertex {
+	w, h := bbox.Xr-bbox.Xl, bbox.Yb-bbox.Yt
+	var sites []Vertex
+	if grid {
+		for i := 0; i+1 < len(data); i += 2 {
+			sites = append(sites, Vertex{
+				bbox.Xl + float64(data[i])/255*w,
+				bbox.Yt + float64(data[i+1])/255*h,
+			})
+		}
+		return sites
+	}
+	for i := 0; i+7 < len(data); i += 8 {
+		x := binary.LittleEndian.Uint32(data[i:])
+		y := binary.LittleEndian.Uint32(data[i+4:])
+		sites = append(sites, Vertex{
+			bbox.Xl + float64(x)/(1<<32-1)*w,
+			bbox.Yt + float64(y)/(1<<32-1)*h,
+		})
+	}
+	return sites
+}
+
+func FuzzCreateDiagram(f *testing.F) {
+	// коллинеарные, квадрат, дубликаты, окружность вокруг центра
+	f.Add([]byte{10, 128, 60, 128, 200, 128, 250, 128}, true)
+	f.Add([]byte{64, 64, 192, 64, 64, 192, 192, 192}, true)
+	f.Add([]byte{5, 5, 5, 5, 100, 7, 5, 5, 100, 7}, true)
+	f.Add([]byte{128, 28, 228, 128, 128, 228, 28, 128, 128, 128, 199, 199}, true)
+	f.Add([]byte{0, 0, 255, 255, 0, 255, 255, 0}, true)
+	// четыре точки на одной окружности: две вершины на расстоянии ulp
+	f.Add([]byte{24, 14, 17, 15, 25, 15, 21, 23}, true)
+	// почти коллинеарные сайты: центры окружностей далеко за bbox
+	f.Add([]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}, true)
+	f.Add([]byte{
+		1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16,
+		200, 100, 50, 25, 12, 6, 3, 1, 255, 255, 255, 127, 0, 0, 0, 128,
+	}, false)
+
+	bbox := NewBoundingBox(0, 1000, 0, 1000)
+	f.Fuzz(func(t *testing.T, data []byte, grid bool) {
+		sites := fuzzSites(data, grid, bbox)
+		if len(sites) == 0 || len(sites) > 512 {
+			return
+		}
+		checkDiagram(t, CreateDiagram(sites, bbox, true, nil))
+	})
+}
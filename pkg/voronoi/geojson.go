@@ -0,0 +1,173 @@
+package voronoi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// GeoJSON FeatureCollection (RFC 7946)
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// GeoJSON Feature
+type Feature struct {
+	Type       string         `json:"type"`
+	Geometry   Geometry       `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+// GeoJSON Geometry. Coordinates - [x, y], [][x, y] или [][][x, y] в зависимости от Type
+type Geometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+// GeoJSON возвращает диаграмму как FeatureCollection: полигоны ячеек
+// (properties: kind="cell", index, input, site, area, data), ребра LineString
+// (kind="edge", left, right - индексы ячеек, right = null у границы bbox)
+// и точки сайтов (kind="site", index, input, data). Индексы указывают на Diagram.Cells,
+// input - на входной слайс (Cell.Index), data есть только у сайтов с данными.
+// Кольца полигонов замкнуты и обходятся против часовой стрелки (ось Y вверх),
+// поэтому полигоны корректны только для замкнутых ячеек
+func (d *Diagram) GeoJSON() *FeatureCollection {
+	fc := &FeatureCollection{
+		Type:     "FeatureCollection",
+		Features: make([]Feature, 0, 2*len(d.Cells)+len(d.Edges)),
+	}
+
+	for i, cell := range d.Cells {
+		polygon := cell.Polygon()
+		if len(polygon) < 3 {
+			continue
+		}
+		ring := make([][2]float64, 0, len(polygon)+1)
+		for _, p := range polygon {
+			ring = append(ring, [2]float64{p.X, p.Y})
+		}
+		if polygonSignedArea(polygon) < 0 {
+			for l, r := 0, len(ring)-1; l < r; l, r = l+1, r-1 {
+				ring[l], ring[r] = ring[r], ring[l]
+			}
+		}
+		ring = append(ring, ring[0])
+
+		properties := map[string]any{
+			"kind":  "cell",
+			"index": i,
+			"input": cell.index,
+			"site":  [2]float64{cell.site.X, cell.site.Y},
+			"area":  cell.Area(),
+		}
+		if cell.data != nil {
+			properties["data"] = cell.data
+		}
+		fc.Features = append(fc.Features, Feature{
+			Type:       "Feature",
+			Geometry:   Geometry{Type: "Polygon", Coordinates: [][][2]float64{ring}},
+			Properties: properties,
+		})
+	}
+
+	for _, edge := range d.Edges {
+		var right any
+		if edge.RightCell != nil {
+			right = edge.RightCell.id
+		}
+		fc.Features = append(fc.Features, Feature{
+			Type: "Feature",
+			Geometry: Geometry{Type: "LineString", Coordinates: [][2]float64{
+				{edge.Va.X, edge.Va.Y},
+				{edge.Vb.X, edge.Vb.Y},
+			}},
+			Properties: map[string]any{
+				"kind":  "edge",
+				"left":  edge.LeftCell.id,
+				"right": right,
+			},
+		})
+	}
+
+	for i, cell := range d.Cells {
+		properties := map[string]any{"kind": "site", "index": i, "input": cell.index}
+		if cell.data != nil {
+			properties["data"] = cell.data
+		}
+		fc.Features = append(fc.Features, Feature{
+			Type:       "Feature",
+			Geometry:   Geometry{Type: "Point", Coordinates: [2]float64{cell.site.X, cell.site.Y}},
+			Properties: properties,
+		})
+	}
+	return fc
+}
+
+// WriteGeoJSON пишет d.GeoJSON() в w
+func (d *Diagram) WriteGeoJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(d.GeoJSON())
+}
+
+// ReadGeoJSONSites читает сайты из GeoJSON: FeatureCollection, Feature или
+// голой геометрии типа Point или MultiPoint. Другие геометрии - ошибка ErrGeoJSON
+func ReadGeoJSONSites(r io.Reader) ([]Vertex, error) {
+	var obj struct {
+		geoJSONGeometry
+		Features []struct {
+			Geometry geoJSONGeometry `json:"geometry"`
+		} `json:"features"`
+		Geometry geoJSONGeometry `json:"geometry"`
+	}
+	if err := json.NewDecoder(r).Decode(&obj); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrGeoJSON, err)
+	}
+
+	switch obj.Type {
+	case "FeatureCollection":
+		var sites []Vertex
+		for i, feature := range obj.Features {
+			points, err := feature.Geometry.points()
+			if err != nil {
+				return nil, fmt.Errorf("features[%d]: %w", i, err)
+			}
+			sites = append(sites, points...)
+		}
+		return sites, nil
+	case "Feature":
+		return obj.Geometry.points()
+	}
+	return obj.geoJSONGeometry.points()
+}
+
+// геометрия при чтении: координаты разбираются после того, как известен тип
+type geoJSONGeometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// координаты геометрии Point или MultiPoint
+func (g geoJSONGeometry) points() ([]Vertex, error) {
+	switch g.Type {
+	case "Point":
+		var c []float64
+		if err := json.Unmarshal(g.Coordinates, &c); err != nil || len(c) < 2 {
+			return nil, fmt.Errorf("%w: bad Point coordinates", ErrGeoJSON)
+		}
+		return []Vertex{{c[0], c[1]}}, nil
+	case "MultiPoint":
+		var cs [][]float64
+		if err := json.Unmarshal(g.Coordinates, &cs); err != nil {
+			return nil, fmt.Errorf("%w: bad MultiPoint coordinates", ErrGeoJSON)
+		}
+		points := make([]Vertex, 0, len(cs))
+		for _, c := range cs {
+			if len(c) < 2 {
+				return nil, fmt.Errorf("%w: bad MultiPoint coordinates", ErrGeoJSON)
+			}
+			points = append(points, Vertex{c[0], c[1]})
+		}
+		return points, nil
+	}
+	return nil, fmt.Errorf("%w: unsupported geometry %q", ErrGeoJSON, g.Type)
+}
@@ -11,53 +11,55 @@ type Vertex struct {
 
 var NO_VERTEX = Vertex{math.Inf(1), math.Inf(1)}
 
-type vetrices []Vertex
-
-func (s vetrices) Len() int      { return len(s) }
-func (s vetrices) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
-
-type verticesByY struct{ vetrices }
+// Конец ребра. Edges заполняется только вызовом Diagram.Graph
+type EdgeVertex struct {
+	Vertex
+	Edges []*Edge
+}
 
-func (s verticesByY) Less(i, j int) bool { return s.vetrices[i].Y < s.vetrices[j].Y }
+// Ребро диаграммы между двумя ячейками.
+// У граничных ребер (по bbox) RightCell равен nil
+type Edge struct {
+	LeftCell  *Cell
+	RightCell *Cell
+	Va        EdgeVertex
+	Vb        EdgeVertex
+}
 
-type edgeVertex struct {
-	Vertex
-	Edges []*edge
+// Endpoints возвращает концы ребра
+func (e *Edge) Endpoints() (Vertex, Vertex) {
+	return e.Va.Vertex, e.Vb.Vertex
 }
 
-type edge struct {
-	LeftCell  *cell
-	RightCell *cell
-	Va        edgeVertex
-	Vb        edgeVertex
+// IsBorder сообщает, лежит ли ребро на границе bbox
+func (e *Edge) IsBorder() bool {
+	return e.RightCell == nil
 }
 
-func newEdge(LeftCell, RightCell *cell) *edge {
-	return &edge{
+func newEdge(LeftCell, RightCell *Cell) *Edge {
+	return &Edge{
 		LeftCell:  LeftCell,
 		RightCell: RightCell,
-		Va:        edgeVertex{NO_VERTEX, nil},
-		Vb:        edgeVertex{NO_VERTEX, nil},
+		Va:        EdgeVertex{NO_VERTEX, nil},
+		Vb:        EdgeVertex{NO_VERTEX, nil},
 	}
 }
 
-type halfEdge struct {
-	Cell  *cell
-	Edge  *edge
+// Полуребро - ребро, ориентированное относительно ячейки Cell
+type HalfEdge struct {
+	Cell  *Cell
+	Edge  *Edge
 	Angle float64
 }
 
-type halfEdges []*halfEdge
-
-func (s halfEdges) Len() int      { return len(s) }
-func (s halfEdges) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
-
-type halfEdgesByAngle struct{ halfEdges }
-
-func (s halfEdgesByAngle) Less(i, j int) bool { return s.halfEdges[i].Angle > s.halfEdges[j].Angle }
+func newHalfEdge(edge *Edge, LeftCell, RightCell *Cell) *HalfEdge {
+	ret := &HalfEdge{}
+	initHalfEdge(ret, edge, LeftCell, RightCell)
+	return ret
+}
 
-func newHalfEdge(edge *edge, LeftCell, RightCell *cell) *halfEdge {
-	ret := &halfEdge{
+func initHalfEdge(ret *HalfEdge, edge *Edge, LeftCell, RightCell *Cell) {
+	*ret = HalfEdge{
 		Cell: LeftCell,
 		Edge: edge,
 	}
@@ -74,10 +76,10 @@ func newHalfEdge(edge *edge, LeftCell, RightCell *cell) *halfEdge {
 			ret.Angle = math.Atan2(va.X-vb.X, vb.Y-va.Y)
 		}
 	}
-	return ret
 }
 
-func (h *halfEdge) startPoint() Vertex {
+// StartPoint возвращает начало полуребра при обходе ячейки
+func (h *HalfEdge) StartPoint() Vertex {
 	if h.Edge.LeftCell == h.Cell {
 		return h.Edge.Va.Vertex
 	}
@@ -85,7 +87,8 @@ func (h *halfEdge) startPoint() Vertex {
 
 }
 
-func (h *halfEdge) endPoint() Vertex {
+// EndPoint возвращает конец полуребра при обходе ячейки
+func (h *HalfEdge) EndPoint() Vertex {
 	if h.Edge.LeftCell == h.Cell {
 		return h.Edge.Vb.Vertex
 	}
@@ -0,0 +1,88 @@
+package voronoi
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// go test ./pkg/voronoi -run TestGolden -update перезаписывает эталоны
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+var goldenCases = []struct {
+	name  string
+	sites []Vertex
+	bbox  BoundingBox
+}{
+	{"two", []Vertex{{2, 5}, {8, 5}}, NewBoundingBox(0, 10, 0, 10)},
+	{"triangle", []Vertex{{2, 2}, {8, 3}, {4, 8}}, NewBoundingBox(0, 10, 0, 10)},
+	{"square", []Vertex{{3, 3}, {7, 3}, {3, 7}, {7, 7}}, NewBoundingBox(0, 10, 0, 10)},
+	{"grid3x3", gridSites(3, 3, NewBoundingBox(0, 9, 0, 9)), NewBoundingBox(0, 9, 0, 9)},
+	{"collinear", []Vertex{{1, 5}, {3, 5}, {6, 5}, {9, 5}}, NewBoundingBox(0, 10, 0, 10)},
+	{"duplicates", []Vertex{{2, 2}, {2, 2}, {8, 3}, {4, 8}, {8, 3}}, NewBoundingBox(0, 10, 0, 10)},
+	{"scatter", []Vertex{
+		{12.5, 40.1}, {77.3, 12.8}, {45.0, 55.5}, {90.2, 88.8},
+		{5.7, 95.1}, {60.4, 30.3}, {33.3, 77.7}, {81.9, 52.6},
+	}, NewBoundingBox(0, 100, 0, 100)},
+}
+
+func TestGolden(t *testing.T) {
+	for _, c := range goldenCases {
+		t.Run(c.name, func(t *testing.T) {
+			d := mustBuild(t, c.sites, c.bbox)
+			got, err := json.MarshalIndent(roundJSON(d.JSON()), "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			path := filepath.Join("testdata", "golden", c.name+".json")
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create)", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s differs from golden output:\n%s", path, got)
+			}
+		})
+	}
+}
+
+// округляет координаты, чтобы эталоны не зависели от последних битов
+// арифметики на разных платформах
+func roundJSON(d *DiagramJSON) *DiagramJSON {
+	round := func(points [][2]float64) {
+		for i, p := range points {
+			points[i] = [2]float64{roundCoord(p[0]), roundCoord(p[1])}
+		}
+	}
+	round(d.Sites)
+	round(d.Vertices)
+	round(d.Duplicates)
+	for _, c := range d.Cells {
+		round(c.Polygon)
+	}
+	return d
+}
+
+func roundCoord(x float64) float64 {
+	x = math.Round(x*1e6) / 1e6
+	if x == 0 {
+		// -0 и 0 должны кодироваться одинаково
+		return 0
+	}
+	return x
+}
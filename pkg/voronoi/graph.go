@@ -0,0 +1,88 @@
+package voronoi
+
+import "math"
+
+// Граф диаграммы: вершины Вороного (включая точки на границе bbox) и ребра
+// между ними. Подходит для поиска путей вдоль границ ячеек
+type Graph struct {
+	Vertices []GraphVertex
+	Edges    []GraphEdge
+}
+
+// Вершина графа. ID - позиция в Graph.Vertices
+type GraphVertex struct {
+	ID    int
+	Point Vertex
+	// Инцидентные ребра (индексы в Graph.Edges)
+	Edges []int
+}
+
+// Degree возвращает число инцидентных ребер
+func (v GraphVertex) Degree() int {
+	return len(v.Edges)
+}
+
+// Ребро графа. ID - позиция в Graph.Edges, A и B - индексы концов в Graph.Vertices
+type GraphEdge struct {
+	ID     int
+	A, B   int
+	Edge   *Edge
+	Length float64
+}
+
+// Other возвращает другой конец ребра
+func (e GraphEdge) Other(vertex int) int {
+	if vertex == e.A {
+		return e.B
+	}
+	return e.A
+}
+
+// Graph строит граф вершин и ребер диаграммы. Ребра графа идут в порядке
+// Diagram.Edges, вершины нумеруются в порядке первого появления.
+// Заодно заполняет EdgeVertex.Edges у ребер диаграммы
+func (d *Diagram) Graph() *Graph {
+	g := &Graph{
+		Vertices: make([]GraphVertex, 0, len(d.Edges)),
+		Edges:    make([]GraphEdge, 0, len(d.Edges)),
+	}
+
+	ids := make(map[Vertex]int, len(d.Edges))
+	vertexID := func(p Vertex) int {
+		id, ok := ids[p]
+		if !ok {
+			id = len(g.Vertices)
+			ids[p] = id
+			g.Vertices = append(g.Vertices, GraphVertex{ID: id, Point: p})
+		}
+		return id
+	}
+
+	for _, edge := range d.Edges {
+		a, b := vertexID(edge.Va.Vertex), vertexID(edge.Vb.Vertex)
+		id := len(g.Edges)
+		g.Edges = append(g.Edges, GraphEdge{
+			ID:     id,
+			A:      a,
+			B:      b,
+			Edge:   edge,
+			Length: math.Hypot(edge.Vb.X-edge.Va.X, edge.Vb.Y-edge.Va.Y),
+		})
+		g.Vertices[a].Edges = append(g.Vertices[a].Edges, id)
+		g.Vertices[b].Edges = append(g.Vertices[b].Edges, id)
+	}
+
+	// ребра диаграммы, сходящиеся в каждой вершине
+	incident := make([][]*Edge, len(g.Vertices))
+	for _, v := range g.Vertices {
+		incident[v.ID] = make([]*Edge, len(v.Edges))
+		for k, e := range v.Edges {
+			incident[v.ID][k] = g.Edges[e].Edge
+		}
+	}
+	for _, e := range g.Edges {
+		e.Edge.Va.Edges = incident[e.A]
+		e.Edge.Vb.Edges = incident[e.B]
+	}
+	return g
+}
@@ -0,0 +1,95 @@
+package voronoi
+
+// Представление диаграммы для JSON. Точки - пары [x, y]
+type DiagramJSON struct {
+	BBox BoundingBox `json:"bbox"`
+	// Сайты в порядке Diagram.Cells
+	Sites [][2]float64 `json:"sites"`
+	// Уникальные вершины ребер
+	Vertices [][2]float64 `json:"vertices"`
+	Edges    []EdgeJSON   `json:"edges"`
+	Cells    []CellJSON   `json:"cells"`
+	// Отброшенные дубликаты и их позиции во входных данных
+	Duplicates      [][2]float64 `json:"duplicates,omitempty"`
+	DuplicateInputs []int        `json:"duplicateInputs,omitempty"`
+}
+
+// Ребро: индексы концов в Vertices и ячеек по обе стороны
+type EdgeJSON struct {
+	A    int `json:"a"`
+	B    int `json:"b"`
+	Left int `json:"left"`
+	// null у граничного ребра
+	Right *int `json:"right"`
+}
+
+// Ячейка: индекс сайта, многоугольник и соседние ячейки
+type CellJSON struct {
+	Site int `json:"site"`
+	// Позиция сайта во входных данных (Cell.Index)
+	Input int `json:"input"`
+	// Данные сайта из BuildSites
+	Data      any          `json:"data,omitempty"`
+	Polygon   [][2]float64 `json:"polygon"`
+	Neighbors []int        `json:"neighbors"`
+}
+
+// JSON собирает представление диаграммы для кодирования в JSON
+func (d *Diagram) JSON() *DiagramJSON {
+	out := &DiagramJSON{
+		BBox:     d.BBox,
+		Sites:    make([][2]float64, 0, len(d.Cells)),
+		Vertices: make([][2]float64, 0, len(d.Edges)),
+		Edges:    make([]EdgeJSON, 0, len(d.Edges)),
+		Cells:    make([]CellJSON, 0, len(d.Cells)),
+	}
+
+	vertexIDs := make(map[Vertex]int)
+	vertexID := func(p Vertex) int {
+		id, ok := vertexIDs[p]
+		if !ok {
+			id = len(out.Vertices)
+			vertexIDs[p] = id
+			out.Vertices = append(out.Vertices, [2]float64{p.X, p.Y})
+		}
+		return id
+	}
+
+	for _, edge := range d.Edges {
+		e := EdgeJSON{
+			A:    vertexID(edge.Va.Vertex),
+			B:    vertexID(edge.Vb.Vertex),
+			Left: edge.LeftCell.id,
+		}
+		if edge.RightCell != nil {
+			right := edge.RightCell.id
+			e.Right = &right
+		}
+		out.Edges = append(out.Edges, e)
+	}
+
+	for i, cell := range d.Cells {
+		out.Sites = append(out.Sites, [2]float64{cell.site.X, cell.site.Y})
+
+		c := CellJSON{
+			Site:      i,
+			Input:     cell.index,
+			Data:      cell.data,
+			Polygon:   make([][2]float64, 0, len(cell.halfEdges)),
+			Neighbors: make([]int, 0, len(cell.halfEdges)),
+		}
+		for _, p := range cell.Polygon() {
+			c.Polygon = append(c.Polygon, [2]float64{p.X, p.Y})
+		}
+		for _, n := range cell.Neighbors() {
+			c.Neighbors = append(c.Neighbors, n.id)
+		}
+		out.Cells = append(out.Cells, c)
+	}
+
+	for _, p := range d.Duplicates {
+		out.Duplicates = append(out.Duplicates, [2]float64{p.X, p.Y})
+	}
+	out.DuplicateInputs = d.DuplicateIndices
+	return out
+}
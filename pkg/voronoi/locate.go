@@ -0,0 +1,108 @@
+package voronoi
+
+import (
+	"math"
+	"sort"
+)
+
+// Индекс для поиска ячейки по точке.
+// Точка принадлежит ячейке сайта с наименьшим |p - s|^2 - w (у Build все веса
+// нулевые, и это просто ближайший сайт; у BuildPower - степенное расстояние),
+// поэтому поиск сводится к поиску ближайшего соседа в k-d дереве по сайтам
+type Locator struct {
+	bbox BoundingBox
+	// k-d дерево, неявно хранящееся в слайсе: корень поддерева [lo, hi) - элемент (lo+hi)/2,
+	// на четной глубине разбиение по X, на нечетной - по Y
+	tree []*Cell
+	// maxWeight[(lo+hi)/2] - наибольший вес в поддереве [lo, hi)
+	maxWeight []float64
+}
+
+// NewLocator строит индекс по ячейкам диаграммы за O(n log^2 n).
+// Точки вне d.BBox не принадлежат ни одной ячейке
+func NewLocator(d *Diagram) *Locator {
+	l := &Locator{
+		bbox:      d.BBox,
+		tree:      make([]*Cell, len(d.Cells)),
+		maxWeight: make([]float64, len(d.Cells)),
+	}
+	copy(l.tree, d.Cells)
+	l.build(0, len(l.tree), 0)
+	return l
+}
+
+func (l *Locator) build(lo, hi, depth int) float64 {
+	if hi <= lo {
+		return math.Inf(-1)
+	}
+	part := l.tree[lo:hi]
+	if depth%2 == 0 {
+		sort.Slice(part, func(i, j int) bool { return part[i].site.X < part[j].site.X })
+	} else {
+		sort.Slice(part, func(i, j int) bool { return part[i].site.Y < part[j].site.Y })
+	}
+	mid := (lo + hi) / 2
+	w := max(l.tree[mid].weight, l.build(lo, mid, depth+1), l.build(mid+1, hi, depth+1))
+	l.maxWeight[mid] = w
+	return w
+}
+
+// Locate возвращает ячейку, содержащую точку p, или nil, если p вне bbox
+func (l *Locator) Locate(p Vertex) *Cell {
+	if len(l.tree) == 0 || !l.bbox.contains(p) {
+		return nil
+	}
+	var best *Cell
+	bestDist := 0.0
+	l.nearest(p, 0, len(l.tree), 0, &best, &bestDist)
+	return best
+}
+
+// LocateAll выполняет Locate для каждой точки
+func (l *Locator) LocateAll(points []Vertex) []*Cell {
+	ret := make([]*Cell, len(points))
+	for i, p := range points {
+		ret[i] = l.Locate(p)
+	}
+	return ret
+}
+
+func (l *Locator) nearest(p Vertex, lo, hi, depth int, best **Cell, bestDist *float64) {
+	if lo >= hi {
+		return
+	}
+	mid := (lo + hi) / 2
+	cell := l.tree[mid]
+	// при равенстве - меньший индекс: дубликат в BuildPower с тем же весом
+	// получает пустую ячейку, а первое вхождение - настоящую
+	if d := sqDist(p, cell.site) - cell.weight; *best == nil || d < *bestDist || (d == *bestDist && cell.index < (*best).index) {
+		*best = cell
+		*bestDist = d
+	}
+
+	// разница вдоль оси разбиения
+	diff := p.X - cell.site.X
+	if depth%2 == 1 {
+		diff = p.Y - cell.site.Y
+	}
+
+	// сначала спускаемся в сторону точки, потом - в другую, если она может быть ближе:
+	// сайты там не ближе |diff|, а их вес не больше наибольшего в поддереве
+	if diff < 0 {
+		l.nearest(p, lo, mid, depth+1, best, bestDist)
+		if mid+1 < hi && diff*diff-l.maxWeight[(mid+1+hi)/2] <= *bestDist {
+			l.nearest(p, mid+1, hi, depth+1, best, bestDist)
+		}
+	} else {
+		l.nearest(p, mid+1, hi, depth+1, best, bestDist)
+		if lo < mid && diff*diff-l.maxWeight[(lo+mid)/2] <= *bestDist {
+			l.nearest(p, lo, mid, depth+1, best, bestDist)
+		}
+	}
+}
+
+func sqDist(a, b Vertex) float64 {
+	dx := a.X - b.X
+	dy := a.Y - b.Y
+	return dx*dx + dy*dy
+}
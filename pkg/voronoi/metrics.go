@@ -0,0 +1,120 @@
+package voronoi
+
+import "math"
+
+// Метрики ячеек имеют смысл только для замкнутых ячеек (closeCells = true)
+
+// Area возвращает площадь ячейки
+func (t *Cell) Area() float64 {
+	return math.Abs(polygonSignedArea(t.Polygon()))
+}
+
+// Centroid возвращает центр масс ячейки.
+// Для вырожденной ячейки возвращается сам сайт
+func (t *Cell) Centroid() Vertex {
+	if c, ok := polygonCentroid(t.Polygon()); ok {
+		return c
+	}
+	return t.site
+}
+
+// Perimeter возвращает периметр ячейки
+func (t *Cell) Perimeter() float64 {
+	var perimeter float64
+	for _, he := range t.halfEdges {
+		a, b := he.StartPoint(), he.EndPoint()
+		perimeter += math.Hypot(b.X-a.X, b.Y-a.Y)
+	}
+	return perimeter
+}
+
+// Bounds возвращает ограничивающий прямоугольник ячейки
+func (t *Cell) Bounds() BoundingBox {
+	b := BoundingBox{math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)}
+	for _, p := range t.Polygon() {
+		b.Xl = math.Min(b.Xl, p.X)
+		b.Xr = math.Max(b.Xr, p.X)
+		b.Yt = math.Min(b.Yt, p.Y)
+		b.Yb = math.Max(b.Yb, p.Y)
+	}
+	return b
+}
+
+// Compactness возвращает изопериметрический коэффициент 4*pi*S/P^2:
+// 1 для круга, меньше - для вытянутых ячеек
+func (t *Cell) Compactness() float64 {
+	perimeter := t.Perimeter()
+	if perimeter == 0 {
+		return 0
+	}
+	return 4 * math.Pi * t.Area() / (perimeter * perimeter)
+}
+
+// Сводная статистика по ячейкам диаграммы
+type Stats struct {
+	Cells int
+
+	TotalArea  float64
+	MinArea    float64
+	MaxArea    float64
+	MeanArea   float64
+	StdDevArea float64
+
+	MeanPerimeter   float64
+	MeanCompactness float64
+}
+
+// Stats считает статистику площадей, периметров и компактности ячеек
+func (d *Diagram) Stats() Stats {
+	st := Stats{Cells: len(d.Cells)}
+	if st.Cells == 0 {
+		return st
+	}
+
+	st.MinArea = math.Inf(1)
+	st.MaxArea = math.Inf(-1)
+	var sumSq float64
+	for _, cell := range d.Cells {
+		area := cell.Area()
+		st.TotalArea += area
+		sumSq += area * area
+		st.MinArea = math.Min(st.MinArea, area)
+		st.MaxArea = math.Max(st.MaxArea, area)
+		st.MeanPerimeter += cell.Perimeter()
+		st.MeanCompactness += cell.Compactness()
+	}
+
+	n := float64(st.Cells)
+	st.MeanArea = st.TotalArea / n
+	st.StdDevArea = math.Sqrt(math.Max(0, sumSq/n-st.MeanArea*st.MeanArea))
+	st.MeanPerimeter /= n
+	st.MeanCompactness /= n
+	return st
+}
+
+// центр масс многоугольника (формула шнурков).
+// ok = false для вырожденного многоугольника
+func polygonCentroid(polygon []Vertex) (c Vertex, ok bool) {
+	area := polygonSignedArea(polygon)
+	if len(polygon) < 3 || area == 0 {
+		return Vertex{}, false
+	}
+	var cx, cy float64
+	for i, p := range polygon {
+		q := polygon[(i+1)%len(polygon)]
+		cross := p.X*q.Y - q.X*p.Y
+		cx += (p.X + q.X) * cross
+		cy += (p.Y + q.Y) * cross
+	}
+	return Vertex{cx / (6 * area), cy / (6 * area)}, true
+}
+
+// площадь многоугольника со знаком (положительная при обходе против часовой стрелки)
+func polygonSignedArea(polygon []Vertex) float64 {
+	var area float64
+	for i, p := range polygon {
+		q := polygon[(i+1)%len(polygon)]
+		area += p.X*q.Y - q.X*p.Y
+	}
+	return area / 2
+}
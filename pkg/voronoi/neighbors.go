@@ -0,0 +1,150 @@
+package voronoi
+
+import "container/heap"
+
+// Индекс для запросов ближайших соседей по графу Делоне диаграммы.
+// Запросы обходят граф от ближайшего сайта в порядке возрастания расстояния:
+// i-й ближайший к точке сайт всегда смежен с одним из более близких, поэтому
+// обход перебирает только сайты-кандидаты и их соседей.
+//
+// Нужна полная триангуляция Делоне, поэтому индекс строится по диаграмме из
+// Build/BuildSites/Builder (не из BuildPower или DynamicDiagram)
+type NeighborIndex struct {
+	cells []*Cell
+	// смежность по ребрам Делоне (индексы в cells)
+	adj [][]int
+}
+
+// NewNeighborIndex строит индекс по ребрам Delaunay() за O(n)
+func NewNeighborIndex(d *Diagram) *NeighborIndex {
+	ix := &NeighborIndex{
+		cells: d.Cells,
+		adj:   make([][]int, len(d.Cells)),
+	}
+	for _, e := range d.Delaunay().Edges {
+		ix.adj[e[0]] = append(ix.adj[e[0]], e[1])
+		ix.adj[e[1]] = append(ix.adj[e[1]], e[0])
+	}
+	return ix
+}
+
+// Neighbors возвращает естественных соседей ячейки - сайты, смежные с ней в
+// триангуляции Делоне. В отличие от Cell.Neighbors, сюда попадают и соседи,
+// общее ребро с которыми лежит за пределами bbox
+func (ix *NeighborIndex) Neighbors(cell *Cell) []*Cell {
+	ret := make([]*Cell, 0, len(ix.adj[cell.id]))
+	for _, j := range ix.adj[cell.id] {
+		ret = append(ret, ix.cells[j])
+	}
+	return ret
+}
+
+// Nearest возвращает ячейку ближайшего к p сайта (nil для пустой диаграммы).
+// В отличие от Locator, p может лежать вне bbox
+func (ix *NeighborIndex) Nearest(p Vertex) *Cell {
+	if len(ix.cells) == 0 {
+		return nil
+	}
+	return ix.cells[ix.nearest(p)]
+}
+
+// KNearest возвращает k ближайших к p сайтов в порядке возрастания расстояния
+// (меньше k, если сайтов меньше; nil при k <= 0)
+func (ix *NeighborIndex) KNearest(p Vertex, k int) []*Cell {
+	if k <= 0 {
+		return nil
+	}
+	var ret []*Cell
+	ix.walk(p, func(cell *Cell, _ float64) bool {
+		if len(ret) == k {
+			return false
+		}
+		ret = append(ret, cell)
+		return true
+	})
+	return ret
+}
+
+// WithinRadius возвращает сайты не дальше r от p в порядке возрастания расстояния
+// (nil при r < 0)
+func (ix *NeighborIndex) WithinRadius(p Vertex, r float64) []*Cell {
+	if r < 0 {
+		return nil
+	}
+	var ret []*Cell
+	ix.walk(p, func(cell *Cell, dist float64) bool {
+		if dist > r*r {
+			return false
+		}
+		ret = append(ret, cell)
+		return true
+	})
+	return ret
+}
+
+// ближайший сайт: жадный спуск по графу Делоне, пока есть сосед ближе
+func (ix *NeighborIndex) nearest(p Vertex) int {
+	i := 0
+	best := sqDist(p, ix.cells[i].site)
+	for {
+		next := -1
+		for _, j := range ix.adj[i] {
+			if dist := sqDist(p, ix.cells[j].site); dist < best {
+				next, best = j, dist
+			}
+		}
+		if next < 0 {
+			return i
+		}
+		i = next
+	}
+}
+
+// обходит сайты в порядке возрастания расстояния до p и передает в visit
+// ячейку и квадрат расстояния, пока visit возвращает true
+func (ix *NeighborIndex) walk(p Vertex, visit func(cell *Cell, sqDist float64) bool) {
+	if len(ix.cells) == 0 {
+		return
+	}
+	start := ix.nearest(p)
+	queue := &siteQueue{{start, sqDist(p, ix.cells[start].site)}}
+	queued := map[int]bool{start: true}
+
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(siteDist)
+		if !visit(ix.cells[item.i], item.dist) {
+			return
+		}
+		for _, j := range ix.adj[item.i] {
+			if !queued[j] {
+				queued[j] = true
+				heap.Push(queue, siteDist{j, sqDist(p, ix.cells[j].site)})
+			}
+		}
+	}
+}
+
+// сайт и квадрат расстояния до точки запроса
+type siteDist struct {
+	i    int
+	dist float64
+}
+
+// очередь с приоритетом по расстоянию для container/heap
+type siteQueue []siteDist
+
+func (q siteQueue) Len() int { return len(q) }
+func (q siteQueue) Less(i, j int) bool {
+	if q[i].dist != q[j].dist {
+		return q[i].dist < q[j].dist
+	}
+	return q[i].i < q[j].i
+}
+func (q siteQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *siteQueue) Push(x any)   { *q = append(*q, x.(siteDist)) }
+func (q *siteQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,38 @@
+package voronoi
+
+// Наблюдатель за ходом алгоритма Форчуна. Методы вызываются синхронно из
+// прохода прямой сканирования; менять переданные ребра нельзя.
+// Для события круга передаются его центр (будущая вершина) и Y прямой сканирования,
+// при котором оно наступает
+type Observer interface {
+	// Событие точки: в пляжную линию добавляется дуга сайта
+	OnSiteEvent(site Vertex)
+	// Событие круга исполняется: дуга исчезает, в center появляется вершина.
+	// Если в вершине сходятся больше трех ячеек, событие сообщается для каждой
+	// исчезающей дуги с одним и тем же center, а OnVertexCreated - один раз
+	OnCircleEvent(center Vertex, sweep float64)
+	// Запланировано событие круга
+	OnCircleEventScheduled(center Vertex, sweep float64)
+	// Запланированное событие круга отменено (ложная тревога)
+	OnCircleEventInvalidated(center Vertex, sweep float64)
+	// Создано ребро. Граничные ребра (закрытие ячеек по bbox) тоже сообщаются
+	OnEdgeCreated(edge *Edge)
+	// Создана вершина диаграммы (до обрезки по bbox). Кроме событий круга, вершину
+	// создает событие точки, попавшей ровно на точку излома пляжной линии
+	OnVertexCreated(vertex Vertex)
+	// Ребро достроено до bbox и обрезано. removed - ребро целиком вне bbox
+	// (или выродилось в точку) и удаляется из диаграммы
+	OnClip(edge *Edge, removed bool)
+}
+
+// Наблюдатель, который ничего не делает. Удобно встраивать в свой тип,
+// чтобы реализовать только нужные методы
+type NopObserver struct{}
+
+func (NopObserver) OnSiteEvent(Vertex)                       {}
+func (NopObserver) OnCircleEvent(Vertex, float64)            {}
+func (NopObserver) OnCircleEventScheduled(Vertex, float64)   {}
+func (NopObserver) OnCircleEventInvalidated(Vertex, float64) {}
+func (NopObserver) OnEdgeCreated(*Edge)                      {}
+func (NopObserver) OnVertexCreated(Vertex)                   {}
+func (NopObserver) OnClip(*Edge, bool)                       {}
@@ -0,0 +1,419 @@
+package voronoi
+
+import (
+	"math"
+	"sort"
+)
+
+// Сайт с весом для диаграммы мощности (power diagram)
+type WeightedSite struct {
+	Vertex
+	// Квадрат "радиуса" сайта: чем больше вес, тем больше ячейка
+	Weight float64
+}
+
+// точка многоугольника ячейки; edge - чьей биссектрисой порождено ребро,
+// начинающееся в этой точке (индекс сайта или borderLabel)
+type powerPoint struct {
+	Vertex
+	edge int
+}
+
+const borderLabel = -1
+
+// BuildPower строит диаграмму мощности: точка x принадлежит ячейке сайта i, если
+// |x - p_i|^2 - w_i минимально. Границы ячеек - прямые (степенные биссектрисы),
+// поэтому результат имеет ту же форму, что и у Build: ячейки, ребра с LeftCell/RightCell,
+// обрезка по bbox и граничные ребра при opts.CloseCells.
+//
+// Ячейки идут в порядке sites (Diagram.Cells[i] - ячейка sites[i]). Ячейка сайта,
+// полностью "перекрытого" соседями, пуста; сайт может лежать вне своей ячейки.
+// Сайты с одинаковыми координатами и весом попадают в Diagram.Duplicates
+// и получают пустые ячейки.
+//
+// Каждая ячейка строится отсечением bbox полуплоскостями остальных сайтов с допуском
+// из Epsilon/RelativeEpsilon; сайты, которые не могут задеть ячейку, отбрасываются
+// по k-d дереву, так что при умеренном разбросе весов построение близко к O(n log n).
+// Общие вершины соседних ячеек склеиваются. Tracer, Observer и RecordSteps не используются,
+// Delaunay() возвращает только ребра (смежность ячеек)
+func BuildPower(sites []WeightedSite, bbox BoundingBox, opts Options) (*Diagram, error) {
+	points := make([]Vertex, len(sites))
+	for i, site := range sites {
+		points[i] = site.Vertex
+		if !isFinite(site.Weight) {
+			return nil, &SiteError{Index: i, Site: site.Vertex, Err: ErrInvalidWeight}
+		}
+	}
+	if err := validateInput(points, bbox); err != nil {
+		return nil, err
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	eps := opts.tolerance(bbox)
+
+	v := &Voronoi{eps: eps}
+	for i, site := range sites {
+		cell := newCell(site.Vertex, i)
+		cell.weight = site.Weight
+		cell.index = i
+		v.cells = append(v.cells, cell)
+	}
+
+	duplicate := make([]bool, len(sites))
+	seen := make(map[WeightedSite]bool, len(sites))
+	for i, site := range sites {
+		if seen[site] {
+			duplicate[i] = true
+			v.duplicates = append(v.duplicates, site.Vertex)
+			v.duplicateIndices = append(v.duplicateIndices, i)
+		}
+		seen[site] = true
+	}
+
+	tree := newPowerTree(sites)
+	shared := make(map[[2]int]*Edge)
+	for i := range v.cells {
+		if duplicate[i] {
+			continue
+		}
+		v.addPolygon(shared, i, tree.powerCell(duplicate, i, bbox, eps), opts.CloseCells)
+	}
+	v.weldVertices()
+
+	return &Diagram{
+		Cells:            v.cells,
+		Edges:            v.edges,
+		BBox:             bbox,
+		Duplicates:       v.duplicates,
+		DuplicateIndices: v.duplicateIndices,
+		delaunayEdges:    v.delaunayEdges,
+		inputCells:       v.cells,
+		eps:              eps,
+	}, nil
+}
+
+// добавляет ребра многоугольника ячейки v.cells[i]. Метки ребер - индексы в v.cells,
+// shared - общие ребра соседних ячеек по паре индексов (меньший, больший)
+func (v *Voronoi) addPolygon(shared map[[2]int]*Edge, i int, polygon []powerPoint, closeCells bool) {
+	cell := v.cells[i]
+	for k, a := range polygon {
+		b := polygon[(k+1)%len(polygon)].Vertex
+
+		if a.edge == borderLabel {
+			if closeCells {
+				edge := v.createBorderEdge(cell, a.Vertex, b)
+				cell.halfEdges = append(cell.halfEdges, newHalfEdge(edge, cell, nil))
+			}
+			continue
+		}
+
+		j := a.edge
+		key := [2]int{min(i, j), max(i, j)}
+		edge := shared[key]
+		if edge == nil {
+			edge = newEdge(cell, v.cells[j])
+			edge.Va.Vertex = a.Vertex
+			edge.Vb.Vertex = b
+			shared[key] = edge
+			v.edges = append(v.edges, edge)
+			v.delaunayEdges = append(v.delaunayEdges, key)
+		}
+		cell.halfEdges = append(cell.halfEdges, newHalfEdge(edge, cell, v.cells[j]))
+	}
+}
+
+// k-d дерево по сайтам диаграммы мощности, устроено как в Locator: корень
+// поддерева [lo, hi) - элемент (lo+hi)/2, на четной глубине разбиение по X,
+// на нечетной - по Y
+type powerTree struct {
+	sites []WeightedSite
+	// индексы в sites
+	tree []int
+	// maxWeight[(lo+hi)/2] - наибольший вес в поддереве [lo, hi)
+	maxWeight []float64
+	// очередь обхода, переиспользуется между ячейками
+	queue powerQueue
+}
+
+func newPowerTree(sites []WeightedSite) *powerTree {
+	t := &powerTree{
+		sites:     sites,
+		tree:      make([]int, len(sites)),
+		maxWeight: make([]float64, len(sites)),
+	}
+	for i := range t.tree {
+		t.tree[i] = i
+	}
+	t.build(0, len(t.tree), 0)
+	return t
+}
+
+func (t *powerTree) build(lo, hi, depth int) float64 {
+	if hi <= lo {
+		return math.Inf(-1)
+	}
+	part := t.tree[lo:hi]
+	if depth%2 == 0 {
+		sort.Slice(part, func(i, j int) bool { return t.sites[part[i]].X < t.sites[part[j]].X })
+	} else {
+		sort.Slice(part, func(i, j int) bool { return t.sites[part[i]].Y < t.sites[part[j]].Y })
+	}
+	mid := (lo + hi) / 2
+	w := max(t.sites[t.tree[mid]].Weight, t.build(lo, mid, depth+1), t.build(mid+1, hi, depth+1))
+	t.maxWeight[mid] = w
+	return w
+}
+
+// многоугольник ячейки i: bbox, отсеченный степенными биссектрисами остальных сайтов.
+// Обход - как у ячеек Build (отрицательная площадь при оси Y вверх).
+// Сайты перебираются по k-d дереву от ближних к дальним, поддеревья, которые
+// не могут отсечь от текущего многоугольника ни одной точки, пропускаются
+func (t *powerTree) powerCell(skip []bool, i int, bbox BoundingBox, eps float64) []powerPoint {
+	c := &powerClipper{
+		tree:    t,
+		skip:    skip,
+		i:       i,
+		eps:     eps,
+		polygon: bboxPolygon(bbox),
+	}
+	c.updateRadius()
+	if !c.walk(bbox) {
+		return nil
+	}
+	return c.polygon
+}
+
+// состояние построения одной ячейки
+type powerClipper struct {
+	tree    *powerTree
+	skip    []bool
+	i       int
+	eps     float64
+	polygon []powerPoint
+	// наибольшее расстояние от сайта i до вершин многоугольника
+	radius float64
+}
+
+func (c *powerClipper) updateRadius() {
+	pi := c.tree.sites[c.i]
+	c.radius = 0
+	for _, p := range c.polygon {
+		c.radius = max(c.radius, math.Hypot(p.X-pi.X, p.Y-pi.Y))
+	}
+}
+
+// может ли сайт на расстоянии не меньше dist от сайта i и с весом не больше
+// weight отсечь часть многоугольника. Многоугольник лежит в круге радиуса R
+// вокруг p_i, и для его точек |x - p_j|^2 - w_j >= (dist - R)^2 - weight,
+// а |x - p_i|^2 - w_i <= R^2 - w_i
+func (c *powerClipper) canCut(dist, weight float64) bool {
+	r := c.radius
+	if dist <= r {
+		return true
+	}
+	return (dist-r)*(dist-r)-weight < r*r-c.tree.sites[c.i].Weight
+}
+
+// отсекает многоугольник сайтами дерева в порядке возрастания расстояния от сайта i:
+// ближние сайты сразу сужают многоугольник, и дальние поддеревья отбрасываются
+// по canCut целиком. Возвращает false, если ячейка пуста
+func (c *powerClipper) walk(bbox BoundingBox) bool {
+	pi := c.tree.sites[c.i].Vertex
+	queue := c.tree.queue[:0]
+	defer func() { c.tree.queue = queue[:0] }()
+	queue.push(powerItem{lo: 0, hi: len(c.tree.tree), rect: bbox, site: -1})
+
+	// наибольший вес среди всех сайтов
+	maxWeight := c.tree.maxWeight[len(c.tree.tree)/2]
+	for len(queue) > 0 {
+		item := queue.pop()
+		// остальные элементы не ближе, и ни один их сайт уже не режет многоугольник
+		if !c.canCut(item.dist, maxWeight) {
+			break
+		}
+		if item.site >= 0 {
+			if !c.clip(item.site) {
+				return false
+			}
+			continue
+		}
+		lo, hi := item.lo, item.hi
+		mid := (lo + hi) / 2
+		if !c.canCut(item.dist, c.tree.maxWeight[mid]) {
+			continue
+		}
+
+		j := c.tree.tree[mid]
+		pm := c.tree.sites[j]
+		queue.push(powerItem{dist: math.Hypot(pm.X-pi.X, pm.Y-pi.Y), site: j})
+
+		left, right := item.rect, item.rect
+		if item.depth%2 == 0 {
+			left.Xr, right.Xl = pm.X, pm.X
+		} else {
+			left.Yb, right.Yt = pm.Y, pm.Y
+		}
+		if dist := rectDistance(left, pi); lo < mid && c.canCut(dist, c.tree.maxWeight[(lo+mid)/2]) {
+			queue.push(powerItem{lo: lo, hi: mid, depth: item.depth + 1, rect: left, site: -1, dist: dist})
+		}
+		if dist := rectDistance(right, pi); mid+1 < hi && c.canCut(dist, c.tree.maxWeight[(mid+1+hi)/2]) {
+			queue.push(powerItem{lo: mid + 1, hi: hi, depth: item.depth + 1, rect: right, site: -1, dist: dist})
+		}
+	}
+	return true
+}
+
+// расстояние от p до прямоугольника (0, если p внутри)
+func rectDistance(rect BoundingBox, p Vertex) float64 {
+	dx := max(rect.Xl-p.X, p.X-rect.Xr, 0)
+	dy := max(rect.Yt-p.Y, p.Y-rect.Yb, 0)
+	return math.Hypot(dx, dy)
+}
+
+// элемент обхода: поддерево [lo, hi) с областью rect или сайт (site >= 0);
+// dist - нижняя оценка расстояния до сайта i
+type powerItem struct {
+	lo, hi, depth int
+	rect          BoundingBox
+	site          int
+	dist          float64
+}
+
+// двоичная куча по dist. Не через container/heap: элементы крупные, и упаковка
+// в any на каждой вставке заметна при десятках вставок на ячейку
+type powerQueue []powerItem
+
+func (q *powerQueue) push(item powerItem) {
+	*q = append(*q, item)
+	h := *q
+	for k := len(h) - 1; k > 0; {
+		parent := (k - 1) / 2
+		if h[parent].dist <= h[k].dist {
+			break
+		}
+		h[parent], h[k] = h[k], h[parent]
+		k = parent
+	}
+}
+
+func (q *powerQueue) pop() powerItem {
+	h := *q
+	top := h[0]
+	last := len(h) - 1
+	h[0] = h[last]
+	h = h[:last]
+	for k := 0; ; {
+		least := k
+		if l := 2*k + 1; l < len(h) && h[l].dist < h[least].dist {
+			least = l
+		}
+		if r := 2*k + 2; r < len(h) && h[r].dist < h[least].dist {
+			least = r
+		}
+		if least == k {
+			break
+		}
+		h[k], h[least] = h[least], h[k]
+		k = least
+	}
+	*q = h
+	return top
+}
+
+// отсекает многоугольник полуплоскостью сайта j. Возвращает false, если ячейка пуста
+func (c *powerClipper) clip(j int) bool {
+	if j == c.i || c.skip[j] {
+		return true
+	}
+	pi, pj := c.tree.sites[c.i], c.tree.sites[j]
+	if !c.canCut(math.Hypot(pj.X-pi.X, pj.Y-pi.Y), pj.Weight) {
+		return true
+	}
+	// полуплоскость ячейки i относительно j: a*x + b*y <= c
+	a := 2 * (pj.X - pi.X)
+	b := 2 * (pj.Y - pi.Y)
+	cc := pj.X*pj.X + pj.Y*pj.Y - pi.X*pi.X - pi.Y*pi.Y + pi.Weight - pj.Weight
+
+	if a == 0 && b == 0 {
+		// совпадающие координаты: побеждает больший вес
+		return cc >= 0
+	}
+
+	// многоугольник выпуклый: прямая его не режет, если все вершины внутри
+	tol := c.eps * math.Hypot(a, b)
+	outside := false
+	for _, p := range c.polygon {
+		if a*p.X+b*p.Y-cc > tol {
+			outside = true
+			break
+		}
+	}
+	if !outside {
+		return true
+	}
+
+	c.polygon = clipPolygon(c.polygon, a, b, cc, j, c.eps)
+	if len(c.polygon) == 0 {
+		return false
+	}
+	c.updateRadius()
+	return true
+}
+
+// bbox как многоугольник с граничными ребрами, обход - как у ячеек Build
+func bboxPolygon(bbox BoundingBox) []powerPoint {
+	return []powerPoint{
+		{Vertex{bbox.Xl, bbox.Yt}, borderLabel},
+		{Vertex{bbox.Xl, bbox.Yb}, borderLabel},
+		{Vertex{bbox.Xr, bbox.Yb}, borderLabel},
+		{Vertex{bbox.Xr, bbox.Yt}, borderLabel},
+	}
+}
+
+// отсечение выпуклого многоугольника полуплоскостью a*x + b*y <= c (Сазерленд-Ходжмен).
+// Точки ближе eps к прямой считаются внутри. Новое ребро вдоль прямой
+// помечается label, ребра короче eps удаляются
+func clipPolygon(polygon []powerPoint, a, b, c float64, label int, eps float64) []powerPoint {
+	// side - расстояние со знаком до прямой, умноженное на |(a, b)|
+	side := func(p Vertex) float64 {
+		return a*p.X + b*p.Y - c
+	}
+	tol := eps * math.Hypot(a, b)
+	ret := make([]powerPoint, 0, len(polygon)+1)
+
+	for k, p := range polygon {
+		q := polygon[(k+1)%len(polygon)]
+		sp, sq := side(p.Vertex), side(q.Vertex)
+		pIn, qIn := sp <= tol, sq <= tol
+
+		if pIn {
+			ret = append(ret, p)
+		}
+		if pIn != qIn {
+			t := sp / (sp - sq)
+			cross := Vertex{p.X + t*(q.X-p.X), p.Y + t*(q.Y-p.Y)}
+			if pIn {
+				// выходим из полуплоскости: дальше идем по отсекающей прямой
+				ret = append(ret, powerPoint{cross, label})
+			} else {
+				// входим обратно: дальше - по исходному ребру
+				ret = append(ret, powerPoint{cross, p.edge})
+			}
+		}
+	}
+
+	// убираем ребра нулевой длины
+	out := make([]powerPoint, 0, len(ret))
+	for k, p := range ret {
+		next := ret[(k+1)%len(ret)]
+		if equalEps(p.X, next.X, eps) && equalEps(p.Y, next.Y, eps) {
+			continue
+		}
+		out = append(out, p)
+	}
+	if len(out) < 3 {
+		return nil
+	}
+	return out
+}
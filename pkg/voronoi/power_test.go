@@ -0,0 +1,184 @@
+package voronoi
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// с нулевыми весами диаграмма мощности совпадает с обычной
+func TestPowerZeroWeightsMatchBuild(t *testing.T) {
+	r := rand.New(rand.NewSource(13))
+	bbox := NewBoundingBox(0, 1000, 0, 500)
+	for k := 0; k < 20; k++ {
+		sites := randomSites(r, 2+r.Intn(100), bbox)
+		weighted := make([]WeightedSite, len(sites))
+		for i, p := range sites {
+			weighted[i] = WeightedSite{Vertex: p}
+		}
+		got, err := BuildPower(weighted, bbox, Options{CloseCells: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := mustBuild(t, sites, bbox)
+		for i := range sites {
+			ga, wa := got.CellOf(i).Area(), want.CellOf(i).Area()
+			if math.Abs(ga-wa) > testEps*wa {
+				t.Fatalf("site %v: power area %v, Build area %v", sites[i], ga, wa)
+			}
+		}
+	}
+}
+
+// соседние ячейки должны сходиться в одних и тех же вершинах на любом масштабе
+func TestPowerDCELAtLargeScale(t *testing.T) {
+	r := rand.New(rand.NewSource(14))
+	for _, size := range []float64{100, 4e6, 1e9} {
+		bbox := NewBoundingBox(0, size, 0, size)
+		for k := 0; k < 30; k++ {
+			sites := randomSites(r, 50, bbox)
+			weighted := make([]WeightedSite, len(sites))
+			for i, p := range sites {
+				weighted[i] = WeightedSite{Vertex: p, Weight: r.Float64() * size * size / 400}
+			}
+			d, err := BuildPower(weighted, bbox, Options{CloseCells: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			dcel, err := d.DCEL()
+			if err != nil {
+				t.Fatalf("size %v: %v", size, err)
+			}
+			if err := dcel.Validate(); err != nil {
+				t.Fatalf("size %v: %v", size, err)
+			}
+		}
+	}
+}
+
+// отсечение по k-d дереву дает те же ячейки, что и перебор всех полуплоскостей
+func TestPowerMatchesBruteForce(t *testing.T) {
+	r := rand.New(rand.NewSource(15))
+	bbox := NewBoundingBox(0, 1000, 0, 500)
+	eps := Options{}.tolerance(bbox)
+	for k := 0; k < 50; k++ {
+		sites := randomSites(r, 2+r.Intn(300), bbox)
+		weighted := make([]WeightedSite, len(sites))
+		for i, p := range sites {
+			weighted[i] = WeightedSite{Vertex: p, Weight: r.Float64() * 1e4}
+		}
+		// один тяжелый сайт накрывает почти весь bbox
+		weighted[r.Intn(len(weighted))].Weight = 1e6
+		d, err := BuildPower(weighted, bbox, Options{CloseCells: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range weighted {
+			polygon := bboxPolygon(bbox)
+			for j, pj := range weighted {
+				pi := weighted[i]
+				if j == i {
+					continue
+				}
+				a, b := 2*(pj.X-pi.X), 2*(pj.Y-pi.Y)
+				c := pj.X*pj.X + pj.Y*pj.Y - pi.X*pi.X - pi.Y*pi.Y + pi.Weight - pj.Weight
+				if polygon = clipPolygon(polygon, a, b, c, j, eps); len(polygon) == 0 {
+					break
+				}
+			}
+			var want float64
+			if len(polygon) > 0 {
+				want = math.Abs(polygonSignedArea(powerVertices(polygon)))
+			}
+			if got := d.CellOf(i).Area(); math.Abs(got-want) > testEps*500*1000 {
+				t.Fatalf("site %v: area %v, brute force %v", weighted[i], got, want)
+			}
+		}
+	}
+}
+
+func powerVertices(polygon []powerPoint) []Vertex {
+	ret := make([]Vertex, len(polygon))
+	for i, p := range polygon {
+		ret[i] = p.Vertex
+	}
+	return ret
+}
+
+// сайт, перекрытый тяжелым соседом, получает пустую ячейку: Locate и ClipToRegion
+// должны считать ее пустой, а не отдавать точки ближайшему сайту
+func TestPowerDominatedSite(t *testing.T) {
+	bbox := NewBoundingBox(0, 100, 0, 100)
+	sites := []WeightedSite{
+		{Vertex: Vertex{20, 50}, Weight: 3000},
+		{Vertex: Vertex{60, 50}},
+		{Vertex: Vertex{90, 50}},
+		// дубликат первого сайта тоже пуст
+		{Vertex: Vertex{20, 50}, Weight: 3000},
+	}
+	d, err := BuildPower(sites, bbox, Options{CloseCells: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(d.DuplicateIndices, []int{3}) {
+		t.Fatalf("got duplicate indices %v, want [3]", d.DuplicateIndices)
+	}
+	for _, i := range []int{1, 3} {
+		if area := d.CellOf(i).Area(); area != 0 {
+			t.Fatalf("cell %d: area %v, want empty", i, area)
+		}
+	}
+
+	l := NewLocator(d)
+	if cell := l.Locate(Vertex{55, 50}); cell != d.CellOf(0) {
+		t.Fatalf("Locate({55, 50}) = cell %d, want 0", cell.Index())
+	}
+	if cell := l.Locate(Vertex{20, 50}); cell != d.CellOf(0) {
+		t.Fatalf("Locate({20, 50}) = cell %d, want 0", cell.Index())
+	}
+
+	region := Region{Outer: []Vertex{{10, 10}, {90, 10}, {90, 90}, {10, 90}}}
+	cd, err := d.ClipToRegion(region)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var area float64
+	for i, cell := range cd.Cells {
+		if (i == 1 || i == 3) && len(cell.Polygons) != 0 {
+			t.Fatalf("empty cell %d clipped to %d polygons", i, len(cell.Polygons))
+		}
+		area += cell.Area()
+	}
+	if math.Abs(area-80*80) > testEps*80*80 {
+		t.Fatalf("clipped area %v, want %v", area, 80*80)
+	}
+}
+
+// Locate на диаграмме мощности сверяется с перебором степенных расстояний
+func TestPowerLocatorMatchesBruteForce(t *testing.T) {
+	r := rand.New(rand.NewSource(16))
+	bbox := NewBoundingBox(0, 1000, 0, 500)
+	for k := 0; k < 20; k++ {
+		sites := randomSites(r, 1+r.Intn(200), bbox)
+		weighted := make([]WeightedSite, len(sites))
+		for i, p := range sites {
+			weighted[i] = WeightedSite{Vertex: p, Weight: r.Float64() * 1e4}
+		}
+		d, err := BuildPower(weighted, bbox, Options{CloseCells: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		l := NewLocator(d)
+		for _, p := range randomSites(r, 200, bbox) {
+			best := math.Inf(1)
+			for _, s := range weighted {
+				best = math.Min(best, sqDist(p, s.Vertex)-s.Weight)
+			}
+			cell := l.Locate(p)
+			if got := sqDist(p, cell.Site()) - cell.Weight(); got > best+testEps*(1+math.Abs(best)) {
+				t.Fatalf("point %v: located %v at %v, best %v", p, cell.Site(), got, best)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package voronoi
+
+import (
+	"math"
+	"math/big"
+)
+
+// Адаптивные геометрические предикаты. Сначала определитель считается во
+// float64 и сравнивается с оценкой ошибки округления (Shewchuk, "Adaptive
+// Precision Floating-Point Arithmetic and Fast Robust Geometric Predicates").
+// Если знак не гарантирован, определитель пересчитывается точно в big.Rat.
+// Знак результата всегда точный, модуль - только приближение
+
+// машинный эпсилон: половина расстояния от 1 до следующего float64
+const machineEps = 1.0 / (1 << 53)
+
+var (
+	orientErrBound   = (3 + 16*machineEps) * machineEps
+	incircleErrBound = (10 + 96*machineEps) * machineEps
+)
+
+// orient2d > 0, если c лежит слева от направленной прямой a->b (в осях y вверх
+// обход a, b, c против часовой стрелки), < 0 - справа, 0 - точки на одной прямой
+func orient2d(a, b, c Vertex) float64 {
+	left := (a.X - c.X) * (b.Y - c.Y)
+	right := (a.Y - c.Y) * (b.X - c.X)
+	det := left - right
+
+	bound := orientErrBound * (math.Abs(left) + math.Abs(right))
+	if det > bound || -det > bound {
+		return det
+	}
+	return orient2dExact(a, b, c)
+}
+
+// incircle > 0, если d лежит внутри окружности через a, b, c при orient2d(a, b, c) > 0
+// (при обратной ориентации знак меняется), 0 - четыре точки на одной окружности
+func incircle(a, b, c, d Vertex) float64 {
+	adx, ady := a.X-d.X, a.Y-d.Y
+	bdx, bdy := b.X-d.X, b.Y-d.Y
+	cdx, cdy := c.X-d.X, c.Y-d.Y
+
+	bdxcdy, cdxbdy := bdx*cdy, cdx*bdy
+	cdxady, adxcdy := cdx*ady, adx*cdy
+	adxbdy, bdxady := adx*bdy, bdx*ady
+	alift := adx*adx + ady*ady
+	blift := bdx*bdx + bdy*bdy
+	clift := cdx*cdx + cdy*cdy
+
+	det := alift*(bdxcdy-cdxbdy) + blift*(cdxady-adxcdy) + clift*(adxbdy-bdxady)
+
+	permanent := (math.Abs(bdxcdy)+math.Abs(cdxbdy))*alift +
+		(math.Abs(cdxady)+math.Abs(adxcdy))*blift +
+		(math.Abs(adxbdy)+math.Abs(bdxady))*clift
+	bound := incircleErrBound * permanent
+	if det > bound || -det > bound {
+		return det
+	}
+	return incircleExact(a, b, c, d)
+}
+
+func orient2dExact(a, b, c Vertex) float64 {
+	ax, ay := ratSub(a.X, c.X), ratSub(a.Y, c.Y)
+	bx, by := ratSub(b.X, c.X), ratSub(b.Y, c.Y)
+	det := new(big.Rat).Sub(ratMul(ax, by), ratMul(ay, bx))
+	return float64(det.Sign())
+}
+
+func incircleExact(a, b, c, d Vertex) float64 {
+	adx, ady := ratSub(a.X, d.X), ratSub(a.Y, d.Y)
+	bdx, bdy := ratSub(b.X, d.X), ratSub(b.Y, d.Y)
+	cdx, cdy := ratSub(c.X, d.X), ratSub(c.Y, d.Y)
+
+	lift := func(x, y *big.Rat) *big.Rat { return new(big.Rat).Add(ratMul(x, x), ratMul(y, y)) }
+	cross := func(x1, y1, x2, y2 *big.Rat) *big.Rat { return new(big.Rat).Sub(ratMul(x1, y2), ratMul(x2, y1)) }
+
+	det := ratMul(lift(adx, ady), cross(bdx, bdy, cdx, cdy))
+	det.Add(det, ratMul(lift(bdx, bdy), cross(cdx, cdy, adx, ady)))
+	det.Add(det, ratMul(lift(cdx, cdy), cross(adx, ady, bdx, bdy)))
+	return float64(det.Sign())
+}
+
+// Центр окружности через a, b, c относительно b, посчитанный точно и
+// округленный до float64. Тройка не должна быть коллинеарной
+func circumcenterExact(a, b, c Vertex) (x, y float64) {
+	ax, ay := ratSub(a.X, b.X), ratSub(a.Y, b.Y)
+	cx, cy := ratSub(c.X, b.X), ratSub(c.Y, b.Y)
+
+	d := new(big.Rat).Sub(ratMul(ax, cy), ratMul(ay, cx))
+	d.Add(d, d)
+	ha := new(big.Rat).Add(ratMul(ax, ax), ratMul(ay, ay))
+	hc := new(big.Rat).Add(ratMul(cx, cx), ratMul(cy, cy))
+
+	nx := new(big.Rat).Sub(ratMul(cy, ha), ratMul(ay, hc))
+	ny := new(big.Rat).Sub(ratMul(ax, hc), ratMul(cx, ha))
+	x, _ = nx.Quo(nx, d).Float64()
+	y, _ = ny.Quo(ny, d).Float64()
+	return x, y
+}
+
+// точная разность двух float64
+func ratSub(a, b float64) *big.Rat {
+	r := new(big.Rat).SetFloat64(a)
+	return r.Sub(r, new(big.Rat).SetFloat64(b))
+}
+
+func ratMul(a, b *big.Rat) *big.Rat {
+	return new(big.Rat).Mul(a, b)
+}
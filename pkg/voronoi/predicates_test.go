@@ -0,0 +1,106 @@
+package voronoi
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func sign(x float64) int {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	}
+	return 0
+}
+
+func TestOrient2d(t *testing.T) {
+	cases := []struct {
+		a, b, c Vertex
+		want    int
+	}{
+		{Vertex{0, 0}, Vertex{1, 0}, Vertex{0, 1}, 1},
+		{Vertex{0, 0}, Vertex{0, 1}, Vertex{1, 0}, -1},
+		{Vertex{0.5, 0.5}, Vertex{12, 12}, Vertex{24, 24}, 0},
+		{Vertex{0.5, 0.5}, Vertex{12, 12}, Vertex{24, math.Nextafter(24, 25)}, 1},
+		{Vertex{0.5, 0.5}, Vertex{12, 12}, Vertex{24, math.Nextafter(24, 23)}, -1},
+		{Vertex{1e8, 1e8}, Vertex{1e8 + 1, 1e8 + 1}, Vertex{1e8 + 2, 1e8 + 2}, 0},
+	}
+	for _, c := range cases {
+		if got := sign(orient2d(c.a, c.b, c.c)); got != c.want {
+			t.Errorf("orient2d(%v, %v, %v) sign %d, want %d", c.a, c.b, c.c, got, c.want)
+		}
+	}
+}
+
+func TestIncircle(t *testing.T) {
+	a, b, c := Vertex{5, 0}, Vertex{0, 5}, Vertex{-5, 0}
+	cases := []struct {
+		d    Vertex
+		want int
+	}{
+		{Vertex{0, 0}, 1},
+		{Vertex{10, 10}, -1},
+		{Vertex{3, 4}, 0},
+		{Vertex{-4, -3}, 0},
+		{Vertex{3, math.Nextafter(4, 5)}, -1},
+		{Vertex{3, math.Nextafter(4, 3)}, 1},
+	}
+	for _, tc := range cases {
+		if got := sign(incircle(a, b, c, tc.d)); got != tc.want {
+			t.Errorf("incircle(%v) sign %d, want %d", tc.d, got, tc.want)
+		}
+	}
+}
+
+// почти вырожденные наборы: фильтр должен либо дать верный знак, либо уйти в точный расчет
+func TestPredicatesMatchExact(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	near := func(p Vertex) Vertex {
+		return Vertex{p.X + float64(r.Intn(5)-2)*math.Abs(p.X)*machineEps, p.Y + float64(r.Intn(5)-2)*math.Abs(p.Y)*machineEps}
+	}
+	for i := 0; i < 10000; i++ {
+		a := Vertex{r.Float64() * 100, r.Float64() * 100}
+		b := Vertex{r.Float64() * 100, r.Float64() * 100}
+		k := r.Float64()
+		c := near(Vertex{a.X + k*(b.X-a.X), a.Y + k*(b.Y-a.Y)})
+		if got, want := sign(orient2d(a, b, c)), sign(orient2dExact(a, b, c)); got != want {
+			t.Fatalf("orient2d(%v, %v, %v) sign %d, exact %d", a, b, c, got, want)
+		}
+
+		angle := r.Float64() * 2 * math.Pi
+		d := near(Vertex{50 + 30*math.Cos(angle), 50 + 30*math.Sin(angle)})
+		a, b, c = Vertex{80, 50}, Vertex{50, 80}, Vertex{20, 50}
+		if got, want := sign(incircle(a, b, c, d)), sign(incircleExact(a, b, c, d)); got != want {
+			t.Fatalf("incircle(%v) sign %d, exact %d", d, got, want)
+		}
+	}
+}
+
+// точный центр равноудален от трех сайтов и у почти вырожденных троек,
+// где формула во float64 теряет знаки
+func TestCircumcenterExact(t *testing.T) {
+	x, y := circumcenterExact(Vertex{5, 0}, Vertex{0, 5}, Vertex{-5, 0})
+	if x != 0 || y != -5 {
+		t.Fatalf("center (%v, %v) relative to b, want (0, -5)", x, y)
+	}
+
+	cases := [][3]Vertex{
+		// почти на одной прямой
+		{{19.607843137254903, 23.52941176470588}, {11.76470588235294, 15.686274509803921}, {3.9215686274509802, 7.8431372549019605}},
+		// почти на одной горизонтали
+		{{549.0195752468472, 549.0196078431374}, {549.0196078431374, 29.48835772217446}, {549.0195754796779, 549.0196078431374}},
+	}
+	for _, c := range cases {
+		x, y := circumcenterExact(c[0], c[1], c[2])
+		center := Vertex{c[1].X + x, c[1].Y + y}
+		r := math.Sqrt(sqDist(center, c[1]))
+		for _, p := range []Vertex{c[0], c[2]} {
+			if dist := math.Sqrt(sqDist(center, p)); math.Abs(dist-r) > 1e-12*r {
+				t.Fatalf("center %v: %v from %v, %v from %v", center, r, c[1], dist, p)
+			}
+		}
+	}
+}
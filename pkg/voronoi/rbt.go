@@ -2,6 +2,8 @@ package voronoi
 
 type rbt struct {
 	root *rbtNode
+	// пул узлов (nil - обычное выделение)
+	pool *arena[rbtNode]
 }
 
 type rbtNodeValue interface {
@@ -20,7 +22,13 @@ type rbtNode struct {
 }
 
 func (t *rbt) insertSuccessor(node *rbtNode, vSucc rbtNodeValue) {
-	succ := &rbtNode{value: vSucc}
+	var succ *rbtNode
+	if t.pool != nil {
+		succ = t.pool.alloc()
+		*succ = rbtNode{value: vSucc}
+	} else {
+		succ = &rbtNode{value: vSucc}
+	}
 	vSucc.bindToNode(succ)
 
 	var parent *rbtNode
@@ -0,0 +1,483 @@
+package voronoi
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+)
+
+// Область интереса: простой многоугольник (возможно, невыпуклый) с дырами
+type Region struct {
+	Outer []Vertex
+	Holes [][]Vertex
+}
+
+// Ячейка, обрезанная по области. Polygons - части пересечения ячейки с
+// областью: у каждой [0] - внешний контур, остальные - дыры.
+// Пустой Polygons - ячейка целиком вне области
+type ClippedCell struct {
+	Cell     *Cell
+	Polygons [][][]Vertex
+}
+
+// Кусок ребра диаграммы внутри области
+type ClippedEdge struct {
+	Edge *Edge
+	A, B Vertex
+}
+
+// Диаграмма, обрезанная по области. Cells[i] соответствует Diagram.Cells[i]
+type ClippedDiagram struct {
+	Diagram *Diagram
+	Region  Region
+	Cells   []ClippedCell
+	Edges   []ClippedEdge
+}
+
+// Bounds возвращает ограничивающий прямоугольник внешнего контура
+func (r Region) Bounds() BoundingBox {
+	b := BoundingBox{math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)}
+	for _, p := range r.Outer {
+		b.Xl = math.Min(b.Xl, p.X)
+		b.Xr = math.Max(b.Xr, p.X)
+		b.Yt = math.Min(b.Yt, p.Y)
+		b.Yb = math.Max(b.Yb, p.Y)
+	}
+	return b
+}
+
+// Contains сообщает, лежит ли точка внутри области (правило чет-нечет по всем контурам)
+func (r Region) Contains(p Vertex) bool {
+	inside := ringContains(r.Outer, p)
+	for _, hole := range r.Holes {
+		if ringContains(hole, p) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func (r Region) validate() error {
+	rings := append([][]Vertex{r.Outer}, r.Holes...)
+	for i, ring := range rings {
+		if len(ring) < 3 {
+			return fmt.Errorf("%w: ring %d has %d vertices", ErrInvalidRegion, i, len(ring))
+		}
+		for _, p := range ring {
+			if !isFinite(p.X) || !isFinite(p.Y) {
+				return fmt.Errorf("%w: ring %d has NaN or Inf vertex", ErrInvalidRegion, i)
+			}
+		}
+	}
+	return nil
+}
+
+// BuildInRegion строит диаграмму по прямоугольнику, описанному вокруг region,
+// и обрезает ее по области. Сайты должны лежать в этом прямоугольнике
+func BuildInRegion(sites []Vertex, region Region, opts Options) (*ClippedDiagram, error) {
+	if err := region.validate(); err != nil {
+		return nil, err
+	}
+	opts.CloseCells = true
+	d, err := Build(sites, region.Bounds(), opts)
+	if err != nil {
+		return nil, err
+	}
+	return d.ClipToRegion(region)
+}
+
+// ClipToRegion обрезает диаграмму с замкнутыми ячейками по области: каждая ячейка
+// становится пересечением своей (выпуклой) ячейки Вороного с областью. У
+// невыпуклой области пересечение может распасться на несколько частей.
+// Точки ближе допуска построения к границе ячейки или области считаются
+// лежащими на ней. Для диаграммы с незамкнутыми ячейками возвращается
+// ErrOpenCells, для некорректной области - ErrInvalidRegion
+func (d *Diagram) ClipToRegion(region Region) (*ClippedDiagram, error) {
+	if err := region.validate(); err != nil {
+		return nil, err
+	}
+	if err := d.checkClosed(); err != nil {
+		return nil, err
+	}
+	eps := d.eps
+	if eps == 0 {
+		eps = Options{}.tolerance(d.BBox)
+	}
+
+	cd := &ClippedDiagram{
+		Diagram: d,
+		Region:  region,
+		Cells:   make([]ClippedCell, len(d.Cells)),
+	}
+
+	rings := region.orientedRings()
+	for i, cell := range d.Cells {
+		cd.Cells[i].Cell = cell
+		cd.Cells[i].Polygons = region.clipConvex(rings, cell.Polygon(), eps)
+	}
+
+	for _, edge := range d.Edges {
+		if edge.IsBorder() {
+			continue
+		}
+		for _, piece := range region.clipSegment(edge.Va.Vertex, edge.Vb.Vertex, eps) {
+			cd.Edges = append(cd.Edges, ClippedEdge{Edge: edge, A: piece[0], B: piece[1]})
+		}
+	}
+	return cd, nil
+}
+
+// проверяет, что полуребра каждой ячейки образуют замкнутый контур (CloseCells).
+// Пустые ячейки (перекрытые соседями сайты и дубликаты в BuildPower) пропускаются,
+// но хотя бы одна ячейка должна иметь ребра: без CloseCells их нет у единственной ячейки
+func (d *Diagram) checkClosed() error {
+	closed := false
+	for i, cell := range d.Cells {
+		if len(cell.halfEdges) == 0 {
+			continue
+		}
+		closed = true
+		for k, he := range cell.halfEdges {
+			next := cell.halfEdges[(k+1)%len(cell.halfEdges)]
+			if he.EndPoint() != next.StartPoint() {
+				return fmt.Errorf("%w: cell %d breaks at %v", ErrOpenCells, i, he.EndPoint())
+			}
+		}
+	}
+	if !closed {
+		return fmt.Errorf("%w: no cell has edges", ErrOpenCells)
+	}
+	return nil
+}
+
+// Area возвращает площадь обрезанной ячейки (внешние контуры частей минус дыры)
+func (c ClippedCell) Area() float64 {
+	var area float64
+	for _, polygon := range c.Polygons {
+		for i, ring := range polygon {
+			if i == 0 {
+				area += math.Abs(polygonSignedArea(ring))
+			} else {
+				area -= math.Abs(polygonSignedArea(ring))
+			}
+		}
+	}
+	return math.Max(0, area)
+}
+
+// контуры области в таком обходе, что область лежит слева: внешний
+// с положительной площадью, дыры - с отрицательной
+func (r Region) orientedRings() [][]Vertex {
+	rings := make([][]Vertex, 0, 1+len(r.Holes))
+	for i, ring := range append([][]Vertex{r.Outer}, r.Holes...) {
+		ring = slices.Clone(ring)
+		if (polygonSignedArea(ring) > 0) != (i == 0) {
+			slices.Reverse(ring)
+		}
+		rings = append(rings, ring)
+	}
+	return rings
+}
+
+// точка разреза ребра: параметр вдоль ребра и сама точка
+type ringCut struct {
+	t float64
+	p Vertex
+}
+
+// пересечение области с выпуклым многоугольником clip. rings - контуры
+// области из orientedRings. Граница пересечения собирается из кусков контуров
+// области внутри clip и кусков границы clip внутри области: оба набора
+// ориентированы так, что пересечение слева, и сцепляются в контуры по концам
+func (r Region) clipConvex(rings [][]Vertex, clip []Vertex, eps float64) [][][]Vertex {
+	if len(clip) < 3 {
+		return nil
+	}
+	if polygonSignedArea(clip) < 0 {
+		clip = slices.Clone(clip)
+		slices.Reverse(clip)
+	}
+	box := BoundingBox{math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)}
+	for _, p := range clip {
+		box.Xl, box.Xr = math.Min(box.Xl, p.X-eps), math.Max(box.Xr, p.X+eps)
+		box.Yt, box.Yb = math.Min(box.Yt, p.Y-eps), math.Max(box.Yb, p.Y+eps)
+	}
+
+	clipCuts := make([][]ringCut, len(clip))
+	var pieces [][2]Vertex
+
+	for _, ring := range rings {
+		for j, a := range ring {
+			b := ring[(j+1)%len(ring)]
+			if math.Max(a.X, b.X) < box.Xl || math.Min(a.X, b.X) > box.Xr ||
+				math.Max(a.Y, b.Y) < box.Yt || math.Min(a.Y, b.Y) > box.Yb {
+				continue
+			}
+
+			cuts := []ringCut{{0, a}, {1, b}}
+			for k, c := range clip {
+				e := clip[(k+1)%len(clip)]
+				if t, u, ok := segmentIntersection(a, b, c, e); ok {
+					p := snapPoint(Vertex{a.X + t*(b.X-a.X), a.Y + t*(b.Y-a.Y)}, eps, a, b, c, e)
+					cuts = append(cuts, ringCut{t, p})
+					clipCuts[k] = append(clipCuts[k], ringCut{u, p})
+				}
+				// вершины, лежащие на ребре другого контура (в том числе при наложении ребер)
+				if t, dist := segmentProjection(a, b, c); t > 0 && t < 1 && dist <= eps {
+					cuts = append(cuts, ringCut{t, c})
+				}
+				if u, dist := segmentProjection(c, e, a); u > 0 && u < 1 && dist <= eps {
+					clipCuts[k] = append(clipCuts[k], ringCut{u, a})
+				}
+			}
+
+			for _, piece := range cutPieces(cuts, eps) {
+				mid := Vertex{(piece[0].X + piece[1].X) / 2, (piece[0].Y + piece[1].Y) / 2}
+				dist, k := convexDist(clip, mid)
+				if dist > eps {
+					pieces = append(pieces, piece)
+					continue
+				}
+				// кусок на границе clip остается, только если область с той же
+				// стороны, что и clip; тогда кусок границы clip отбросится ниже
+				if dist >= -eps {
+					c, e := clip[k], clip[(k+1)%len(clip)]
+					if (piece[1].X-piece[0].X)*(e.X-c.X)+(piece[1].Y-piece[0].Y)*(e.Y-c.Y) > 0 {
+						pieces = append(pieces, piece)
+					}
+				}
+			}
+		}
+	}
+
+	for k, c := range clip {
+		e := clip[(k+1)%len(clip)]
+		cuts := append(clipCuts[k], ringCut{0, c}, ringCut{1, e})
+		for _, piece := range cutPieces(cuts, eps) {
+			mid := Vertex{(piece[0].X + piece[1].X) / 2, (piece[0].Y + piece[1].Y) / 2}
+			if r.Contains(mid) && r.borderDist(mid) > eps {
+				pieces = append(pieces, piece)
+			}
+		}
+	}
+
+	return assembleRings(pieces, eps)
+}
+
+// сцепляет куски в замкнутые контуры и группирует их в многоугольники:
+// контуры с положительной площадью - внешние, с отрицательной - дыры
+func assembleRings(pieces [][2]Vertex, eps float64) [][][]Vertex {
+	var outers, holes [][]Vertex
+	used := make([]bool, len(pieces))
+	for s := range pieces {
+		if used[s] {
+			continue
+		}
+		used[s] = true
+		ring := []Vertex{pieces[s][0]}
+		end := pieces[s][1]
+		closed := false
+		for {
+			if math.Sqrt(sqDist(end, ring[0])) <= eps {
+				closed = true
+				break
+			}
+			next, best := -1, eps
+			for k, piece := range pieces {
+				if dist := math.Sqrt(sqDist(piece[0], end)); !used[k] && dist <= best {
+					next, best = k, dist
+					if dist == 0 {
+						break
+					}
+				}
+			}
+			if next < 0 {
+				break
+			}
+			used[next] = true
+			ring = append(ring, end)
+			end = pieces[next][1]
+		}
+		// незамкнутая цепочка - след вырожденного касания, площади у нее нет
+		if !closed || len(ring) < 3 {
+			continue
+		}
+		if polygonSignedArea(ring) > 0 {
+			outers = append(outers, ring)
+		} else {
+			holes = append(holes, ring)
+		}
+	}
+
+	polygons := make([][][]Vertex, len(outers))
+	for i, outer := range outers {
+		polygons[i] = [][]Vertex{outer}
+	}
+	// дыра, которую не накрывает ни один внешний контур, - след вырожденного
+	// касания; приклеить ее к чужому контуру значит получить некорректный многоугольник
+	for _, hole := range holes {
+		for i, outer := range outers {
+			if ringCovers(outer, hole, eps) {
+				polygons[i] = append(polygons[i], hole)
+				break
+			}
+		}
+	}
+	return polygons
+}
+
+// лежат ли все вершины inner внутри ring или ближе eps к его границе
+// (дыра может касаться внешнего контура)
+func ringCovers(ring, inner []Vertex, eps float64) bool {
+	for _, p := range inner {
+		if ringContains(ring, p) {
+			continue
+		}
+		onBorder := false
+		for k, a := range ring {
+			if _, dist := segmentProjection(a, ring[(k+1)%len(ring)], p); dist <= eps {
+				onBorder = true
+				break
+			}
+		}
+		if !onBorder {
+			return false
+		}
+	}
+	return true
+}
+
+// режет ребро по точкам cuts (с концами) на куски длиннее eps
+func cutPieces(cuts []ringCut, eps float64) [][2]Vertex {
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i].t < cuts[j].t })
+	var pieces [][2]Vertex
+	prev := cuts[0].p
+	for _, cut := range cuts[1:] {
+		if math.Sqrt(sqDist(prev, cut.p)) <= eps {
+			continue
+		}
+		pieces = append(pieces, [2]Vertex{prev, cut.p})
+		prev = cut.p
+	}
+	// последний кусок должен кончаться в конце ребра, чтобы куски сцепились
+	if n := len(pieces); n > 0 {
+		pieces[n-1][1] = cuts[len(cuts)-1].p
+	}
+	return pieces
+}
+
+// заменяет p ближайшей из вершин ребер, если она не дальше eps
+func snapPoint(p Vertex, eps float64, vertices ...Vertex) Vertex {
+	for _, v := range vertices {
+		if math.Sqrt(sqDist(p, v)) <= eps {
+			return v
+		}
+	}
+	return p
+}
+
+// наименьшее расстояние со знаком от p до прямых ребер выпуклого многоугольника
+// с положительной площадью (положительное внутри) и номер этого ребра
+func convexDist(polygon []Vertex, p Vertex) (float64, int) {
+	best, edge := math.Inf(1), 0
+	for k, a := range polygon {
+		b := polygon[(k+1)%len(polygon)]
+		length := math.Hypot(b.X-a.X, b.Y-a.Y)
+		if length == 0 {
+			continue
+		}
+		if dist := ((b.X-a.X)*(p.Y-a.Y) - (b.Y-a.Y)*(p.X-a.X)) / length; dist < best {
+			best, edge = dist, k
+		}
+	}
+	return best, edge
+}
+
+// параметр проекции p на отрезок a-b и расстояние от p до отрезка
+func segmentProjection(a, b, p Vertex) (float64, float64) {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	length2 := dx*dx + dy*dy
+	if length2 == 0 {
+		return 0, math.Sqrt(sqDist(a, p))
+	}
+	t := ((p.X-a.X)*dx + (p.Y-a.Y)*dy) / length2
+	c := math.Max(0, math.Min(1, t))
+	return t, math.Hypot(p.X-a.X-c*dx, p.Y-a.Y-c*dy)
+}
+
+// расстояние от p до ближайшего ребра контуров области
+func (r Region) borderDist(p Vertex) float64 {
+	best := math.Inf(1)
+	for _, ring := range append([][]Vertex{r.Outer}, r.Holes...) {
+		for k, a := range ring {
+			_, dist := segmentProjection(a, ring[(k+1)%len(ring)], p)
+			best = math.Min(best, dist)
+		}
+	}
+	return best
+}
+
+// куски отрезка a-b внутри области. Куски короче eps отбрасываются
+func (r Region) clipSegment(a, b Vertex, eps float64) [][2]Vertex {
+	length := math.Hypot(b.X-a.X, b.Y-a.Y)
+	// параметры пересечений отрезка с ребрами всех контуров
+	ts := []float64{0, 1}
+	rings := append([][]Vertex{r.Outer}, r.Holes...)
+	for _, ring := range rings {
+		for k, p := range ring {
+			q := ring[(k+1)%len(ring)]
+			if t, _, ok := segmentIntersection(a, b, p, q); ok {
+				ts = append(ts, t)
+			}
+		}
+	}
+	sort.Float64s(ts)
+
+	at := func(t float64) Vertex {
+		return Vertex{a.X + t*(b.X-a.X), a.Y + t*(b.Y-a.Y)}
+	}
+
+	var pieces [][2]Vertex
+	for k := 1; k < len(ts); k++ {
+		t0, t1 := ts[k-1], ts[k]
+		if (t1-t0)*length <= eps || !r.Contains(at((t0+t1)/2)) {
+			continue
+		}
+		// склеиваем с предыдущим куском, если они продолжают друг друга
+		if n := len(pieces); n > 0 && pieces[n-1][1] == at(t0) {
+			pieces[n-1][1] = at(t1)
+			continue
+		}
+		pieces = append(pieces, [2]Vertex{at(t0), at(t1)})
+	}
+	return pieces
+}
+
+// параметры точки пересечения отрезков a-b и p-q: t на a-b и u на p-q
+func segmentIntersection(a, b, p, q Vertex) (float64, float64, bool) {
+	rx, ry := b.X-a.X, b.Y-a.Y
+	sx, sy := q.X-p.X, q.Y-p.Y
+	denom := rx*sy - ry*sx
+	if denom == 0 {
+		return 0, 0, false
+	}
+	t := ((p.X-a.X)*sy - (p.Y-a.Y)*sx) / denom
+	u := ((p.X-a.X)*ry - (p.Y-a.Y)*rx) / denom
+	if t < 0 || t > 1 || u < 0 || u > 1 {
+		return 0, 0, false
+	}
+	return t, u, true
+}
+
+// точка внутри контура (трассировка луча)
+func ringContains(ring []Vertex, p Vertex) bool {
+	inside := false
+	for k, a := range ring {
+		b := ring[(k+1)%len(ring)]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,158 @@
+package voronoi
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// обрезанные ячейки покрывают область без наложений и не выходят за нее
+func TestClipToRegion(t *testing.T) {
+	regions := map[string]Region{
+		"convex": {Outer: []Vertex{{100, 50}, {400, 20}, {480, 300}, {250, 480}, {30, 260}}},
+		// звезда с глубокими вырезами
+		"concave": {Outer: starRing(Vertex{250, 250}, 240, 60, 7)},
+		// П-образная область с дырой
+		"hole": {
+			Outer: []Vertex{{0, 0}, {500, 0}, {500, 500}, {330, 500}, {330, 150}, {170, 150}, {170, 500}, {0, 500}},
+			Holes: [][]Vertex{{{400, 300}, {450, 300}, {450, 400}, {400, 400}}},
+		},
+	}
+
+	r := rand.New(rand.NewSource(15))
+	for name, region := range regions {
+		t.Run(name, func(t *testing.T) {
+			want := math.Abs(polygonSignedArea(region.Outer))
+			for _, hole := range region.Holes {
+				want -= math.Abs(polygonSignedArea(hole))
+			}
+			bbox := region.Bounds()
+			tol := testEps * (bbox.Xr - bbox.Xl)
+
+			for k := 0; k < 10; k++ {
+				cd, err := BuildInRegion(randomSites(r, 5+r.Intn(200), bbox), region, Options{})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var area float64
+				for i, cell := range cd.Cells {
+					area += cell.Area()
+					for _, ring := range slices.Concat(cell.Polygons...) {
+						for _, p := range ring {
+							if !polygonContains(cell.Cell.Polygon(), p, tol) {
+								t.Fatalf("cell %d: point %v is outside the Voronoi cell", i, p)
+							}
+							if !region.Contains(p) && region.borderDist(p) > tol {
+								t.Fatalf("cell %d: point %v is outside the region", i, p)
+							}
+						}
+					}
+				}
+				if math.Abs(area-want) > testEps*want {
+					t.Fatalf("cells cover %v, region area %v", area, want)
+				}
+
+				for _, e := range cd.Edges {
+					mid := Vertex{(e.A.X + e.B.X) / 2, (e.A.Y + e.B.Y) / 2}
+					if !region.Contains(mid) {
+						t.Fatalf("edge piece %v-%v is outside the region", e.A, e.B)
+					}
+				}
+			}
+		})
+	}
+}
+
+// ребра решетки сайтов совпадают с ребрами области и проходят через ее вершины
+func TestClipToRegionAlignedEdges(t *testing.T) {
+	region := Region{
+		Outer: []Vertex{{0, 0}, {500, 0}, {500, 500}, {300, 500}, {300, 100}, {200, 100}, {200, 500}, {0, 500}},
+		Holes: [][]Vertex{{{400, 300}, {450, 300}, {450, 400}, {400, 400}}},
+	}
+	want := 500*500 - 100*400 - 50*100.0
+	for _, n := range []int{1, 2, 5, 10, 20} {
+		cd, err := BuildInRegion(gridSites(n, n, region.Bounds()), region, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var area float64
+		for _, cell := range cd.Cells {
+			area += cell.Area()
+		}
+		if math.Abs(area-want) > testEps*want {
+			t.Fatalf("%dx%d grid: cells cover %v, region area %v", n, n, area, want)
+		}
+	}
+}
+
+// без CloseCells у ячеек нет контура, и обрезать их нельзя
+func TestClipToRegionOpenCells(t *testing.T) {
+	bbox := NewBoundingBox(0, 100, 0, 100)
+	d, err := Build(randomSites(rand.New(rand.NewSource(16)), 20, bbox), bbox, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	region := Region{Outer: []Vertex{{10, 10}, {90, 10}, {50, 90}}}
+	if _, err := d.ClipToRegion(region); !errors.Is(err, ErrOpenCells) {
+		t.Fatalf("want ErrOpenCells, got %v", err)
+	}
+}
+
+// дыра, касающаяся внешнего контура области, попадает в ту часть ячейки, в которой
+// лежит: ячейка сайта (250, 300) режется П-образной областью на два рукава
+func TestClipToRegionTouchingHole(t *testing.T) {
+	outer := []Vertex{{0, 0}, {500, 0}, {500, 500}, {330, 500}, {330, 150}, {170, 150}, {170, 500}, {0, 500}}
+	holes := map[string][]Vertex{
+		"left outer side":  {{0, 300}, {100, 250}, {100, 350}},
+		"left inner side":  {{170, 300}, {60, 250}, {60, 350}},
+		"right outer side": {{500, 300}, {400, 250}, {400, 350}},
+		"right inner side": {{330, 300}, {440, 250}, {440, 350}},
+	}
+	for name, hole := range holes {
+		t.Run(name, func(t *testing.T) {
+			region := Region{Outer: outer, Holes: [][]Vertex{hole}}
+			cd, err := BuildInRegion([]Vertex{{250, 100}, {250, 300}}, region, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			center := Vertex{(hole[0].X + hole[1].X + hole[2].X) / 3, (hole[0].Y + hole[1].Y + hole[2].Y) / 3}
+			var area float64
+			holesFound := 0
+			for i, cell := range cd.Cells {
+				area += cell.Area()
+				for _, polygon := range cell.Polygons {
+					for _, h := range polygon[1:] {
+						holesFound++
+						if !ringContains(polygon[0], center) {
+							t.Fatalf("cell %d: hole %v attached to outer ring %v", i, h, polygon[0])
+						}
+					}
+				}
+			}
+			if holesFound != 1 {
+				t.Fatalf("got %d holes, want 1", holesFound)
+			}
+			want := math.Abs(polygonSignedArea(outer)) - math.Abs(polygonSignedArea(hole))
+			if math.Abs(area-want) > testEps*want {
+				t.Fatalf("cells cover %v, region area %v", area, want)
+			}
+		})
+	}
+}
+
+// звезда с n лучами: вершины попеременно на радиусах outer и inner
+func starRing(c Vertex, outer, inner float64, n int) []Vertex {
+	ring := make([]Vertex, 0, 2*n)
+	for k := 0; k < 2*n; k++ {
+		rad := outer
+		if k%2 == 1 {
+			rad = inner
+		}
+		angle := math.Pi * float64(k) / float64(n)
+		ring = append(ring, Vertex{c.X + rad*math.Cos(angle), c.Y + rad*math.Sin(angle)})
+	}
+	return ring
+}
@@ -0,0 +1,44 @@
+package voronoi
+
+import "math"
+
+// Параметры релаксации Ллойда
+type RelaxOptions struct {
+	// Остановиться, если за итерацию ни один сайт не сдвинулся больше чем на Tolerance.
+	// 0 - всегда выполнять все итерации
+	Tolerance float64
+	// Вызывается после каждой итерации (нумерация с 1) с новыми сайтами и
+	// максимальным сдвигом. Если вернуть false, релаксация прекращается
+	OnIteration func(iteration int, sites []Vertex, maxShift float64) bool
+}
+
+// Relax выполняет до iterations шагов релаксации Ллойда: строит диаграмму с
+// закрытыми ячейками, переносит каждый сайт в центр масс его ячейки и повторяет.
+// Результат - центроидальное разбиение Вороного. Входной слайс не меняется,
+// порядок сайтов в результате совпадает с порядком на входе
+func Relax(sites []Vertex, bbox BoundingBox, iterations int, opts RelaxOptions) ([]Vertex, error) {
+	cur := make([]Vertex, len(sites))
+	copy(cur, sites)
+
+	for iter := 1; iter <= iterations; iter++ {
+		d, err := Build(cur, bbox, Options{CloseCells: true})
+		if err != nil {
+			return nil, err
+		}
+
+		var maxShift float64
+		for i, site := range cur {
+			c := d.CellOf(i).Centroid()
+			maxShift = math.Max(maxShift, math.Hypot(c.X-site.X, c.Y-site.Y))
+			cur[i] = c
+		}
+
+		if opts.OnIteration != nil && !opts.OnIteration(iter, cur, maxShift) {
+			break
+		}
+		if maxShift < opts.Tolerance {
+			break
+		}
+	}
+	return cur, nil
+}
@@ -1,56 +1,97 @@
 package voronoi
 
 import (
+	"cmp"
 	"math"
-	"sort"
+	"slices"
 
-	"github.com/0x0FACED/go-fortune/pkg/logger"
 	"go.uber.org/zap"
 )
 
 // Основная функция - база
-// Это основной алгоритм, где вызываются остальные функции/методы
-func CreateDiagram(sites []Vertex, bbox BoundingBox, closeCells bool, logger *logger.ZapLogger) *Diagram {
+// Это основной алгоритм, где вызываются остальные функции/методы.
+// tracer может быть nil - тогда трассировка полностью отключена.
+// Слайс sites не меняется, ячейку sites[i] возвращает Diagram.CellOf(i)
+func CreateDiagram(sites []Vertex, bbox BoundingBox, closeCells bool, tracer Tracer) *Diagram {
 	// sites - точки (вершины)
 	v := &Voronoi{
-		cellsMap: make(map[Vertex]*cell),
-		Logger:   logger,
+		cellsMap: make(map[Vertex]*Cell),
+		tracer:   tracer,
+		eps:      defaultEpsilon,
 	}
 
-	logger.Info("[f] Алгоритм Форчуна запущен")
+	return v.run(sites, bbox, closeCells)
+}
+
+// Алгоритм Форчуна: проход прямой сканирования, обрезка ребер и (опционально) закрытие ячеек
+func (v *Voronoi) run(sites []Vertex, bbox BoundingBox, closeCells bool) *Diagram {
+	if v.tracer != nil {
+		v.tracer.Info("[f] Алгоритм Форчуна запущен")
+	}
+	v.sweepEps = sweepTolerance(bbox)
+	v.sweepLimit = sweepLimitFor(bbox)
+
+	// входной слайс не трогаем, а сортируем перестановку индексов: по Y, чтобы
+	// гарантировать обработку сверху вниз (от меньших к большим), при равных Y - по X,
+	// чтобы дубликаты оказались рядом, а среди дубликатов - по индексу, чтобы
+	// ячейка досталась первому вхождению
+	order := v.order[:0]
+	for i := range sites {
+		order = append(order, i)
+	}
+	slices.SortFunc(order, func(i, j int) int {
+		if c := cmp.Compare(sites[i].Y, sites[j].Y); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(sites[i].X, sites[j].X); c != 0 {
+			return c
+		}
+		return cmp.Compare(i, j)
+	})
+	v.order = order
 
-	// сортируем по Y, чтобы гарантировать обработку сверху вниз (от меньших к большим)
-	sort.Sort(verticesByY{sites})
+	// ячейки в порядке входного слайса
+	inputCells := slices.Grow(v.inputCells[:0], len(sites))[:len(sites)]
+	v.inputCells = inputCells
 
-	logger.Info("[f] Сайты (точки) отсортированы по Y", zap.Any("sites", sites))
+	if v.tracer != nil {
+		v.tracer.Info("[f] Сайты (точки) отсортированы по Y", zap.Ints("order", order))
+	}
 	// функция для имитации очереди
-	// получаем первую вершину и удаляем ее из слайса
+	// получаем первую вершину и удаляем ее из очереди, index - ее позиция в sites
+	var index int
 	pop := func() *Vertex {
-		if len(sites) == 0 {
+		if len(order) == 0 {
 			return nil
 		}
 
-		site := sites[0]
-		sites = sites[1:]
-		return &site
+		index = order[0]
+		order = order[1:]
+		return &sites[index]
 	}
 
 	// берем первую вершину
 	site := pop()
 
-	logger.Info("[f] Первая вершина", zap.Any("site", site))
+	if v.tracer != nil {
+		v.tracer.Info("[f] Первая вершина", zap.Any("site", site))
+	}
 	// предыдущие точки
 	prevSiteX := math.SmallestNonzeroFloat64
 	prevSiteY := math.SmallestNonzeroFloat64
 	var circle *circleEvent
 
 	var counter int
-	logger.Info("[f] Основной цикл начат")
+	if v.tracer != nil {
+		v.tracer.Info("[f] Основной цикл начат")
+	}
 	// основной цикл
 	for {
-		v.Logger.Info("[f-for] ===============================================================================================")
-		v.Logger.Info("[f-for] Текущая итерация", zap.Int("c", counter))
-		v.Logger.Info("[f-for] Осталось сайтов", zap.Int("sites", len(sites)))
+		if v.tracer != nil {
+			v.tracer.Info("[f-for] ===============================================================================================")
+			v.tracer.Info("[f-for] Текущая итерация", zap.Int("c", counter))
+			v.tracer.Info("[f-for] Осталось сайтов", zap.Int("sites", len(order)))
+		}
 		counter++
 		// site event - когда мы пересекаем точку
 		// circle event - когда три параболы пересекаются и образуют вершину (пересечение)
@@ -66,40 +107,75 @@ func CreateDiagram(sites []Vertex, bbox BoundingBox, closeCells bool, logger *lo
 			// Проверка на дубликат (нет смысла строить линии для точек, которые расположены
 			// на одинаковых координатах)
 			if site.X != prevSiteX || site.Y != prevSiteY {
-				logger.Info("[f-for-site] Не дубликат", zap.Any("site", site))
+				if v.tracer != nil {
+					v.tracer.Info("[f-for-site] Не дубликат", zap.Any("site", site))
+				}
 				// создаем ячейку для точки
-				nCell := newCell(*site)
-				logger.Info("[f-for-site] Новая ячейка", zap.Any("cell", nCell))
+				nCell := v.allocCell(*site, len(v.cells))
+				nCell.index = index
+				inputCells[index] = nCell
+				if v.tracer != nil {
+					v.tracer.Info("[f-for-site] Новая ячейка", zap.Any("cell", nCell))
+				}
 				// добавляем в структуру вороного в ячейки новую ячейку
 				v.cells = append(v.cells, nCell)
 				// добавляем в мапу
 				v.cellsMap[*site] = nCell
 				// создаем beachsection
-				logger.Info("[f-for-site] Создаем beach section")
+				if v.tracer != nil {
+					v.tracer.Info("[f-for-site] Создаем beach section")
+				}
 				v.addBeachSection(*site)
+				if v.recordSteps {
+					v.steps = append(v.steps, v.snapshot(EventSite, *site, site.Y, bbox))
+				}
 				// запоминаем эти координаты для проверки на дубликаты
 				prevSiteY = site.Y
 				prevSiteX = site.X
 			} else {
-				logger.Error("[f-for-site] Найден дубликат!", zap.Any("site", site))
+				if v.tracer != nil {
+					v.tracer.Error("[f-for-site] Найден дубликат!", zap.Any("site", site))
+				}
+				v.duplicates = append(v.duplicates, *site)
+				v.duplicateIndices = append(v.duplicateIndices, index)
+				inputCells[index] = v.cellsMap[*site]
 			}
 			// достаем следующую точку
 			site = pop()
-			logger.Info("[f-for-site] Следующая точка", zap.Any("site", site))
+			if v.tracer != nil {
+				v.tracer.Info("[f-for-site] Следующая точка", zap.Any("site", site))
+			}
 		} else if circle != nil { // убираем beachsection, если круг не nil
-			logger.Info("[f-for-circle] Данные круга", zap.Float64("x", circle.x), zap.Float64("y", circle.y), zap.Any("arc-site", circle.arc.site))
+			if v.tracer != nil {
+				v.tracer.Info("[f-for-circle] Данные круга", zap.Float64("x", circle.x), zap.Float64("y", circle.y), zap.Any("arc-site", circle.arc.site))
+			}
 			v.removeBeachSection(circle.arc)
+			if v.recordSteps {
+				v.steps = append(v.steps, v.snapshot(EventCircle, Vertex{circle.x, circle.ycenter}, circle.y, bbox))
+			}
 
 		} else { // конец
 			break
 		}
 	}
 
-	logger.Info("[f] Алгоритм завершен!")
+	if v.tracer != nil {
+		v.tracer.Info("[f] Алгоритм завершен!")
+	}
 
+	v.weldVertices()
 	v.clipEdges(bbox)
+	if v.recordSteps {
+		done := Step{Event: EventDone, Sweep: bbox.Yb}
+		for _, edge := range v.edges {
+			done.Edges = append(done.Edges, [2]Vertex{edge.Va.Vertex, edge.Vb.Vertex})
+		}
+		v.steps = append(v.steps, done)
+	}
 
-	logger.Info("[f] Остатки соединены")
+	if v.tracer != nil {
+		v.tracer.Info("[f] Остатки соединены")
+	}
 
 	if closeCells {
 		v.closeCells(bbox)
@@ -109,7 +185,16 @@ func CreateDiagram(sites []Vertex, bbox BoundingBox, closeCells bool, logger *lo
 		}
 	}
 
-	//v.gatherVertexEdges()
-
-	return &Diagram{Edges: v.edges, Cells: v.cells}
+	return &Diagram{
+		Edges:            v.edges,
+		Cells:            v.cells,
+		BBox:             bbox,
+		Duplicates:       v.duplicates,
+		DuplicateIndices: v.duplicateIndices,
+		triangles:        v.triangles,
+		delaunayEdges:    v.delaunayEdges,
+		Steps:            v.steps,
+		inputCells:       inputCells,
+		eps:              v.eps,
+	}
 }
@@ -0,0 +1,128 @@
+package voronoi
+
+import "math"
+
+// Виды шагов алгоритма
+const (
+	EventSite   = "site"
+	EventCircle = "circle"
+	// Последний шаг: ребра обрезаны по bbox
+	EventDone = "done"
+)
+
+// Снимок состояния алгоритма после обработки одного события
+type Step struct {
+	// EventSite, EventCircle или EventDone
+	Event string `json:"event"`
+	// Сайт события точки или вершина (центр круга) события круга
+	Point Vertex `json:"point"`
+	// Положение прямой сканирования
+	Sweep float64 `json:"sweep"`
+	// Дуги пляжной линии слева направо
+	Arcs []StepArc `json:"arcs"`
+	// Запланированные события круга
+	CircleEvents []StepCircle `json:"circleEvents"`
+	// Построенные к этому моменту ребра. Недостроенные ребра
+	// заканчиваются в текущей точке пересечения дуг
+	Edges [][2]Vertex `json:"edges"`
+}
+
+// Дуга пляжной линии: парабола с фокусом Site и директрисой Step.Sweep на отрезке [From, To] по X
+type StepArc struct {
+	Site Vertex  `json:"site"`
+	From float64 `json:"from"`
+	To   float64 `json:"to"`
+}
+
+// Событие круга: наступит, когда прямая сканирования дойдет до Y
+type StepCircle struct {
+	Center Vertex  `json:"center"`
+	Radius float64 `json:"radius"`
+	Y      float64 `json:"y"`
+}
+
+// снимок текущего состояния. Бесконечные границы дуг прижимаются к bbox,
+// чтобы снимок можно было закодировать в JSON
+func (v *Voronoi) snapshot(event string, point Vertex, sweep float64, bbox BoundingBox) Step {
+	step := Step{Event: event, Point: point, Sweep: sweep}
+
+	clampX := func(x float64) float64 {
+		if math.IsNaN(x) {
+			return bbox.Xl
+		}
+		return math.Max(bbox.Xl, math.Min(bbox.Xr, x))
+	}
+
+	// точки пересечения дуг, через которые сейчас тянутся ребра
+	breakPoints := make(map[*Edge][]Vertex)
+
+	var node *rbtNode
+	if v.beachline.root != nil {
+		node = v.beachline.getFirst(v.beachline.root)
+	}
+	from := bbox.Xl
+	for ; node != nil; node = node.next {
+		arc := node.value.(*BeachSection)
+		to := bbox.Xr
+		if node.next != nil {
+			right := node.next.value.(*BeachSection)
+			x := breakPoint(arc.site, right.site, sweep)
+			to = clampX(x)
+			if p, ok := breakPointVertex(arc.site, right.site, x, sweep); ok && right.edge != nil {
+				breakPoints[right.edge] = append(breakPoints[right.edge], p)
+			}
+		}
+		step.Arcs = append(step.Arcs, StepArc{Site: arc.site, From: from, To: to})
+		from = to
+	}
+
+	for _, edge := range v.edges {
+		va, vb := edge.Va.Vertex, edge.Vb.Vertex
+		switch {
+		case va != NO_VERTEX && vb != NO_VERTEX:
+			step.Edges = append(step.Edges, [2]Vertex{va, vb})
+		case va != NO_VERTEX || vb != NO_VERTEX:
+			end := va
+			if end == NO_VERTEX {
+				end = vb
+			}
+			for _, p := range breakPoints[edge] {
+				step.Edges = append(step.Edges, [2]Vertex{end, p})
+			}
+		case len(breakPoints[edge]) == 2:
+			// ребро только что родилось внутри разделенной дуги
+			step.Edges = append(step.Edges, [2]Vertex{breakPoints[edge][0], breakPoints[edge][1]})
+		}
+	}
+
+	if v.circleEvents.root != nil {
+		for node := v.circleEvents.getFirst(v.circleEvents.root); node != nil; node = node.next {
+			circle := node.value.(*circleEvent)
+			step.CircleEvents = append(step.CircleEvents, StepCircle{
+				Center: Vertex{circle.x, circle.ycenter},
+				Radius: circle.y - circle.ycenter,
+				Y:      circle.y,
+			})
+		}
+	}
+	return step
+}
+
+// точка пересечения парабол с абсциссой x. Если один из сайтов лежит на директрисе,
+// его парабола вырождена в луч, и Y берется по параболе другого сайта
+func breakPointVertex(lSite, rSite Vertex, x, directrix float64) (Vertex, bool) {
+	focus := rSite
+	if focus.Y == directrix {
+		focus = lSite
+	}
+	if focus.Y == directrix || math.IsInf(x, 0) || math.IsNaN(x) {
+		return Vertex{}, false
+	}
+	return Vertex{x, parabolaY(focus, x, directrix)}, true
+}
+
+// Y параболы с фокусом focus и директрисой y = directrix в точке x
+func parabolaY(focus Vertex, x, directrix float64) float64 {
+	dx := x - focus.X
+	return (dx*dx + focus.Y*focus.Y - directrix*directrix) / (2 * (focus.Y - directrix))
+}
@@ -0,0 +1,104 @@
+package voronoi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Палитра заливки ячеек по умолчанию
+var DefaultPalette = []string{
+	"#8dd3c7", "#ffffb3", "#bebada", "#fb8072", "#80b1d3", "#fdb462",
+	"#b3de69", "#fccde5", "#d9d9d9", "#bc80bd", "#ccebc5", "#ffed6f",
+}
+
+// Параметры вывода в SVG
+type SVGOptions struct {
+	// Размер картинки в пикселях. 0 - размер bbox
+	Width, Height int
+	// Заливать ячейки цветами из Palette (нужны замкнутые ячейки)
+	FillCells bool
+	// Цвета заливки, по кругу. nil - DefaultPalette
+	Palette []string
+	// Радиус точки сайта. 0 - 3 пикселя
+	SiteRadius float64
+	// Толщина линий. 0 - 1 пиксель
+	StrokeWidth float64
+}
+
+// WriteSVG рисует диаграмму в SVG: ячейки (если FillCells), ребра, сайты и рамку bbox.
+// Координаты не преобразуются: ось Y направлена вниз, как в bbox (Yt сверху)
+func (d *Diagram) WriteSVG(w io.Writer, opts SVGOptions) error {
+	bbox := d.BBox
+	bw := bbox.Xr - bbox.Xl
+	bh := bbox.Yb - bbox.Yt
+
+	width, height := opts.Width, opts.Height
+	if width <= 0 {
+		width = max(1, int(bw))
+	}
+	if height <= 0 {
+		height = max(1, int(bh))
+	}
+	palette := opts.Palette
+	if len(palette) == 0 {
+		palette = DefaultPalette
+	}
+	// ширины задаются в пикселях, а рисуем в координатах bbox
+	scale := bw / float64(width)
+	radius := opts.SiteRadius
+	if radius <= 0 {
+		radius = 3
+	}
+	radius *= scale
+	stroke := opts.StrokeWidth
+	if stroke <= 0 {
+		stroke = 1
+	}
+	stroke *= scale
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="%g %g %g %g" preserveAspectRatio="none">`+"\n",
+		width, height, bbox.Xl, bbox.Yt, bw, bh)
+
+	if opts.FillCells {
+		buf.WriteString(`<g stroke="none">` + "\n")
+		for i, cell := range d.Cells {
+			polygon := cell.Polygon()
+			if len(polygon) < 3 {
+				continue
+			}
+			buf.WriteString(`<polygon points="`)
+			for j, p := range polygon {
+				if j > 0 {
+					buf.WriteByte(' ')
+				}
+				fmt.Fprintf(&buf, "%g,%g", p.X, p.Y)
+			}
+			fmt.Fprintf(&buf, `" fill="%s"/>`+"\n", palette[i%len(palette)])
+		}
+		buf.WriteString("</g>\n")
+	}
+
+	fmt.Fprintf(&buf, `<g stroke="black" stroke-width="%g">`+"\n", stroke)
+	for _, edge := range d.Edges {
+		if edge.IsBorder() {
+			continue
+		}
+		fmt.Fprintf(&buf, `<line x1="%g" y1="%g" x2="%g" y2="%g"/>`+"\n", edge.Va.X, edge.Va.Y, edge.Vb.X, edge.Vb.Y)
+	}
+	buf.WriteString("</g>\n")
+
+	fmt.Fprintf(&buf, `<g fill="black">`+"\n")
+	for _, cell := range d.Cells {
+		fmt.Fprintf(&buf, `<circle cx="%g" cy="%g" r="%g"/>`+"\n", cell.site.X, cell.site.Y, radius)
+	}
+	buf.WriteString("</g>\n")
+
+	fmt.Fprintf(&buf, `<rect x="%g" y="%g" width="%g" height="%g" fill="none" stroke="black" stroke-width="%g"/>`+"\n",
+		bbox.Xl, bbox.Yt, bw, bh, 2*stroke)
+	buf.WriteString("</svg>\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
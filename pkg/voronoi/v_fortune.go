@@ -1,22 +1,22 @@
 package voronoi
 
 import (
-	"fmt"
+	"cmp"
 	"math"
+	"slices"
 
-	"github.com/0x0FACED/go-fortune/pkg/logger"
 	"go.uber.org/zap"
 )
 
 // Основная структура
 type Voronoi struct {
 	// ячейки диаграммы Вороного
-	cells []*cell
+	cells []*Cell
 	// ребра диаграммы Вороного
-	edges []*edge
+	edges []*Edge
 
 	// мапа для быстрого доступа к ячейке по координатам (ключу)
-	cellsMap map[Vertex]*cell
+	cellsMap map[Vertex]*Cell
 
 	// Пляжная линия (красно-черное дерево)
 	// динамические меняется при продвижении, охватывает всю высоту от 0 до H
@@ -25,27 +25,92 @@ type Voronoi struct {
 	circleEvents rbt
 	// следующее событие круга
 	firstCircleEvent *circleEvent
+	// отброшенные сайты-дубликаты и их позиции во входном слайсе
+	duplicates       []Vertex
+	duplicateIndices []int
+
+	// двойственная триангуляция Делоне (индексы в cells)
+	triangles     [][3]int
+	delaunayEdges [][2]int
+
+	// трассировка хода алгоритма (nil - выключена)
+	tracer Tracer
+	// наблюдатель за событиями алгоритма (nil - нет)
+	observer Observer
+
+	// пулы объектов (только у Builder)
+	pools *pools
+	// буфер исчезающих дуг для removeBeachSection
+	transitions BeachSectionPtrs
+	// перестановка сайтов в порядке прохода и ячейки в порядке входного слайса
+	order      []int
+	inputCells []*Cell
+	// буферы weldVertices
+	vertexRefs []*EdgeVertex
+	welded     []bool
+
+	// допуск склейки вершин, обрезки и замыкания ячеек (см. Options.Epsilon)
+	eps float64
+	// внутренний допуск прохода прямой и построения биссектрис (см. sweepTolerance),
+	// от Options не зависит
+	sweepEps float64
+	// события круга позже этой позиции прямой пропускаются (см. sweepLimitFor)
+	sweepLimit float64
+
+	// записывать снимки после каждого события
+	recordSteps bool
+	steps       []Step
+}
 
-	Logger *logger.ZapLogger
+// Трассировщик хода алгоритма. Подходит *logger.ZapLogger из pkg/logger
+type Tracer interface {
+	Info(msg string, fields ...zap.Field)
+	Error(msg string, fields ...zap.Field)
 }
 
 // Структура диаграммы
 type Diagram struct {
-	Cells []*cell
-	Edges []*edge
+	Cells []*Cell
+	Edges []*Edge
+	// Область, по которой обрезана диаграмма
+	BBox BoundingBox
+	// Сайты, отброшенные как дубликаты (по одному на каждое повторение)
+	Duplicates []Vertex
+	// Позиции дубликатов во входном слайсе (как у Cell.Index), в порядке Duplicates
+	DuplicateIndices []int
+
+	// Пошаговая запись алгоритма (только с Options.RecordSteps)
+	Steps []Step
+
+	triangles     [][3]int
+	delaunayEdges [][2]int
+	// ячейки в порядке входных сайтов (см. CellOf)
+	inputCells []*Cell
+	// допуск построения (Options.tolerance)
+	eps float64
+}
+
+// CellOf возвращает ячейку сайта sites[i] из входного слайса построения.
+// Дубликаты делят ячейку первого вхождения. nil, если i вне диапазона
+// или диаграмма получена из DynamicDiagram
+func (d *Diagram) CellOf(i int) *Cell {
+	if i < 0 || i >= len(d.inputCells) {
+		return nil
+	}
+	return d.inputCells[i]
 }
 
-func (s *Voronoi) cell(site Vertex) *cell {
+func (s *Voronoi) cell(site Vertex) *Cell {
 	ret := s.cellsMap[site]
 	if ret == nil {
-		panic(fmt.Sprintf("Couldn't find cell for site %v", site))
+		panic(&SiteError{Index: -1, Site: site, Err: ErrCellNotFound})
 	}
 	return ret
 }
 
 // Создание ребра
-func (s *Voronoi) createEdge(LeftCell, RightCell *cell, va, vb Vertex) *edge {
-	edge := newEdge(LeftCell, RightCell)
+func (s *Voronoi) createEdge(LeftCell, RightCell *Cell, va, vb Vertex) *Edge {
+	edge := s.allocEdge(LeftCell, RightCell)
 	s.edges = append(s.edges, edge)
 	if va != NO_VERTEX {
 		s.setEdgeStartpoint(edge, LeftCell, RightCell, va)
@@ -58,21 +123,31 @@ func (s *Voronoi) createEdge(LeftCell, RightCell *cell, va, vb Vertex) *edge {
 	lCell := LeftCell
 	rCell := RightCell
 
-	lCell.halfEdges = append(lCell.halfEdges, newHalfEdge(edge, LeftCell, RightCell))
-	rCell.halfEdges = append(rCell.halfEdges, newHalfEdge(edge, RightCell, LeftCell))
+	lCell.halfEdges = append(lCell.halfEdges, s.allocHalfEdge(edge, LeftCell, RightCell))
+	rCell.halfEdges = append(rCell.halfEdges, s.allocHalfEdge(edge, RightCell, LeftCell))
+
+	// ребро Вороного между ячейками = ребро Делоне между их сайтами
+	s.delaunayEdges = append(s.delaunayEdges, [2]int{lCell.id, rCell.id})
+
+	if s.observer != nil {
+		s.observer.OnEdgeCreated(edge)
+	}
 	return edge
 }
 
-func (s *Voronoi) createBorderEdge(LeftCell *cell, va, vb Vertex) *edge {
-	edge := newEdge(LeftCell, nil)
+func (s *Voronoi) createBorderEdge(LeftCell *Cell, va, vb Vertex) *Edge {
+	edge := s.allocEdge(LeftCell, nil)
 	edge.Va.Vertex = va
 	edge.Vb.Vertex = vb
 
 	s.edges = append(s.edges, edge)
+	if s.observer != nil {
+		s.observer.OnEdgeCreated(edge)
+	}
 	return edge
 }
 
-func (s *Voronoi) setEdgeStartpoint(edge *edge, LeftCell, RightCell *cell, vertex Vertex) {
+func (s *Voronoi) setEdgeStartpoint(edge *Edge, LeftCell, RightCell *Cell, vertex Vertex) {
 	if edge.Va.Vertex == NO_VERTEX && edge.Vb.Vertex == NO_VERTEX {
 		edge.Va.Vertex = vertex
 		edge.LeftCell = LeftCell
@@ -84,51 +159,74 @@ func (s *Voronoi) setEdgeStartpoint(edge *edge, LeftCell, RightCell *cell, verte
 	}
 }
 
-func (s *Voronoi) setEdgeEndpoint(edge *edge, LeftCell, RightCell *cell, vertex Vertex) {
+func (s *Voronoi) setEdgeEndpoint(edge *Edge, LeftCell, RightCell *Cell, vertex Vertex) {
 	s.setEdgeStartpoint(edge, RightCell, LeftCell, vertex)
 }
 
 func (v *Voronoi) leftBreakPoint(arc *BeachSection, directrix float64) float64 {
 	// получаем сайт по дуге (сайт, с чьей дугой работаем)
 	site := arc.site
-	rfocx := site.X
-	rfocy := site.Y
-	pby2 := rfocy - directrix
-	v.Logger.Info("\t[f-for-add-bs-for-left-bp] (Расстояние) Правая точка пересечения", zap.Float64("right", pby2))
-	if pby2 == 0 {
-		return rfocx
+	if v.tracer != nil {
+		v.tracer.Info("\t[f-for-add-bs-for-left-bp] (Расстояние) Правая точка пересечения", zap.Float64("right", site.Y-directrix))
+	}
+	if site.Y == directrix {
+		return site.X
 	}
 
 	lArc := arc.Node().previous
 	if lArc == nil {
 		return math.Inf(-1)
 	}
-	site = lArc.value.(*BeachSection).site
-	lfocx := site.X
-	lfocy := site.Y
+	lSite := lArc.value.(*BeachSection).site
+	if v.tracer != nil {
+		v.tracer.Info("[f-for-add-bs-for-left-bp] (Расстояние) Левая точка пересечения", zap.Float64("left", lSite.Y-directrix))
+	}
+	res := breakPoint(lSite, site, directrix)
+	if v.tracer != nil {
+		v.tracer.Info("[f-for-add-bs-for-left-bp] Результат", zap.Float64("res", res))
+	}
+	return res
+}
+
+// X точки пересечения парабол левого (lSite) и правого (rSite) сайтов при данной directrix
+func breakPoint(lSite, rSite Vertex, directrix float64) float64 {
+	rfocx := rSite.X
+	rfocy := rSite.Y
+	pby2 := rfocy - directrix
+	if pby2 == 0 {
+		return rfocx
+	}
+	lfocx := lSite.X
+	lfocy := lSite.Y
 	plby2 := lfocy - directrix
-	v.Logger.Info("[f-for-add-bs-for-left-bp] (Расстояние) Левая точка пересечения", zap.Float64("left", plby2))
 	if plby2 == 0 {
 		return lfocx
 	}
+	// u = x - rfocx - корень квадратного уравнения
+	// (pby2 - plby2)u^2 - 2*pby2*hl*u + pby2*(hl^2 - plby2*(pby2 - plby2)) = 0.
+	// Когда фокус почти на directrix, школьная формула -b + sqrt(b^2 - ...)
+	// теряет все знаки, поэтому при сокращении берем корень через произведение
+	// корней (c/a)
 	hl := lfocx - rfocx
-	aby2 := 1/pby2 - 1/plby2
-	b := hl / plby2
-	var res float64
-	if aby2 != 0 {
-		res = (-b+math.Sqrt(b*b-2*aby2*(hl*hl/(-2*plby2)-lfocy+plby2/2+rfocy-pby2/2)))/aby2 + rfocx
-		v.Logger.Info("[f-for-add-bs-for-left-bp] Результат", zap.Float64("res", res))
-		return res
-	}
-	res = (rfocx + lfocx) / 2
-	v.Logger.Info("[f-for-add-bs-for-left-bp] Результат", zap.Float64("res", res))
-	return res
+	// pby2 - plby2, но без округления directrix
+	a := rfocy - lfocy
+	if a == 0 {
+		return (rfocx + lfocx) / 2
+	}
+	t := pby2 * hl
+	sq := math.Sqrt(plby2*pby2) * math.Hypot(hl, a)
+	if t > 0 {
+		return pby2*(hl*hl-plby2*a)/(t+sq) + rfocx
+	}
+	return (t-sq)/a + rfocx
 }
 
 func (v *Voronoi) rightBreakPoint(arc *BeachSection, directrix float64) float64 {
 	rArc := arc.Node().next
 	if rArc != nil {
-		v.Logger.Info("[f-for-add-bs-for-right-bp] Правая nil, идем налево")
+		if v.tracer != nil {
+			v.tracer.Info("[f-for-add-bs-for-right-bp] Правая nil, идем налево")
+		}
 		return v.leftBreakPoint(rArc.value.(*BeachSection), directrix)
 	}
 	site := arc.site
@@ -138,33 +236,68 @@ func (v *Voronoi) rightBreakPoint(arc *BeachSection, directrix float64) float64
 	return math.Inf(1)
 }
 
+// убираем дугу, чье событие круга исполняется (а не отменяется)
 func (s *Voronoi) detachBeachSection(arc *BeachSection) {
-	s.detachCircleEvent(arc)
+	s.removeCircleEvent(arc)
 	s.beachline.removeNode(arc.node)
 }
 
+// дуга исчезает в той же вершине, что и дуга исполняемого события:
+// ее событие круга тоже считается исполненным
+func (v *Voronoi) mergeBeachSection(arc *BeachSection, vertex Vertex, sweep float64) {
+	if v.observer != nil {
+		v.observer.OnCircleEvent(vertex, sweep)
+	}
+	v.detachBeachSection(arc)
+}
+
 func (v *Voronoi) removeBeachSection(bs *BeachSection) {
-	v.Logger.Info("[f-for-rm-bs-for] Начало rm bs", zap.Any("site_bs", bs.circleEvent.site))
+	if v.tracer != nil {
+		v.tracer.Info("[f-for-rm-bs-for] Начало rm bs", zap.Any("site_bs", bs.circleEvent.site))
+	}
 	circle := bs.circleEvent
-	v.Logger.Info("[f-for-rm-bs-for] Текущее событие круга", zap.Float64("site_bs_x", bs.circleEvent.x), zap.Float64("site_bs_y", bs.circleEvent.y))
+	if v.tracer != nil {
+		v.tracer.Info("[f-for-rm-bs-for] Текущее событие круга", zap.Float64("site_bs_x", bs.circleEvent.x), zap.Float64("site_bs_y", bs.circleEvent.y))
+	}
 	x := circle.x
 	y := circle.ycenter
 	vertex := Vertex{x, y}
+	if v.observer != nil {
+		v.observer.OnCircleEvent(vertex, circle.y)
+		v.observer.OnVertexCreated(vertex)
+	}
 	previous := bs.node.previous
 	next := bs.node.next
-	disappearingTransitions := BeachSectionPtrs{bs}
+	// буфер переиспользуется между событиями
+	disappearingTransitions := append(v.transitions[:0], bs)
+	defer func() {
+		clear(disappearingTransitions)
+		v.transitions = disappearingTransitions[:0]
+	}()
 	abs_fn := math.Abs
 
+	// соседняя дуга исчезает в той же вершине, если ее событие круга совпадает
+	// с текущим: центры близки или ее внешний сосед лежит на той же окружности
+	lSite0 := previous.value.(*BeachSection).site
+	rSite0 := next.value.(*BeachSection).site
+	sameCircle := func(arc *BeachSection, outer *rbtNode) bool {
+		if arc.circleEvent == nil {
+			return false
+		}
+		if abs_fn(x-arc.circleEvent.x) < v.sweepEps && abs_fn(y-arc.circleEvent.ycenter) < v.sweepEps {
+			return true
+		}
+		return outer != nil && incircle(lSite0, bs.site, rSite0, outer.value.(*BeachSection).site) == 0
+	}
+
 	v.detachBeachSection(bs)
 
 	lArc := previous.value.(*BeachSection)
-	for lArc.circleEvent != nil &&
-		abs_fn(x-lArc.circleEvent.x) < 1e-9 &&
-		abs_fn(y-lArc.circleEvent.ycenter) < 1e-9 {
+	for sameCircle(lArc, lArc.node.previous) {
 
 		previous = lArc.node.previous
 		disappearingTransitions.appendLeft(lArc)
-		v.detachBeachSection(lArc)
+		v.mergeBeachSection(lArc, vertex, circle.y)
 		lArc = previous.value.(*BeachSection)
 	}
 
@@ -172,12 +305,10 @@ func (v *Voronoi) removeBeachSection(bs *BeachSection) {
 	v.detachCircleEvent(lArc)
 
 	var rArc = next.value.(*BeachSection)
-	for rArc.circleEvent != nil &&
-		abs_fn(x-rArc.circleEvent.x) < 1e-9 &&
-		abs_fn(y-rArc.circleEvent.ycenter) < 1e-9 {
+	for sameCircle(rArc, rArc.node.next) {
 		next = rArc.node.next
 		disappearingTransitions.appendRight(rArc)
-		v.detachBeachSection(rArc)
+		v.mergeBeachSection(rArc, vertex, circle.y)
 		rArc = next.value.(*BeachSection)
 	}
 
@@ -203,12 +334,19 @@ func (v *Voronoi) removeBeachSection(bs *BeachSection) {
 
 	rArc.edge = v.createEdge(lSite, rSite, NO_VERTEX, vertex)
 
+	// все исчезающие дуги лежат на одной окружности - веер треугольников Делоне
+	for iArc := 1; iArc < nArcs-1; iArc++ {
+		v.addTriangle(lSite, v.cell(disappearingTransitions[iArc].site), v.cell(disappearingTransitions[iArc+1].site))
+	}
+
 	v.attachCircleEvent(lArc)
 	v.attachCircleEvent(rArc)
 }
 
 func (v *Voronoi) addBeachSection(site Vertex) {
-	v.Logger.Info("[f-for-add-bs] Входные параметры", zap.Any("site", site))
+	if v.tracer != nil {
+		v.tracer.Info("[f-for-add-bs] Входные параметры", zap.Any("site", site))
+	}
 	// позиция по X
 	x := site.X
 	// линия текущей позиции прямого сканирования
@@ -228,7 +366,9 @@ func (v *Voronoi) addBeachSection(site Vertex) {
 	var dxl, dxr float64
 	node := v.beachline.root
 
-	v.Logger.Info("[f-for-add-bs] Текущая нода", zap.Any("node", node))
+	if v.tracer != nil {
+		v.tracer.Info("[f-for-add-bs] Текущая нода", zap.Any("node", node))
+	}
 	// пока нода не равна nil. Это поиск места для новой дуги
 	// Цикл перебирает дуги на beach line (ДУГИ ПАРАБОЛ), чтобы найти место для новой дуги
 	for node != nil {
@@ -238,46 +378,60 @@ func (v *Voronoi) addBeachSection(site Vertex) {
 		// и левой точкой пересечения текущей параболы с прямой сканирования.
 		dxl = v.leftBreakPoint(nodeBeachline, directrix) - x
 
-		v.Logger.Info("[f-for-add-bs-for] Точка из ноды", zap.Any("site", nodeBeachline.site))
-		v.Logger.Info("[f-for-add-bs-for] Левая точка пересечения параболы", zap.Float64("dxl", dxl))
+		if v.tracer != nil {
+			v.tracer.Info("[f-for-add-bs-for] Точка из ноды", zap.Any("site", nodeBeachline.site))
+			v.tracer.Info("[f-for-add-bs-for] Левая точка пересечения параболы", zap.Float64("dxl", dxl))
+		}
 
-		if dxl > 1e-9 {
-			v.Logger.Info("[f-for-add-bs-for] Новая точка находится СЛЕВА от текущей дуги (параболы)",
-				zap.Float64("dxl", dxl),
-			)
+		if dxl > v.sweepEps {
+			if v.tracer != nil {
+				v.tracer.Info("[f-for-add-bs-for] Новая точка находится СЛЕВА от текущей дуги (параболы)",
+					zap.Float64("dxl", dxl),
+				)
+			}
 			node = node.left
 		} else {
 			dxr = x - v.rightBreakPoint(nodeBeachline, directrix)
-			if dxr > 1e-9 {
-				v.Logger.Info("[f-for-add-bs-for] Новая точка находится СПРАВА от текущей дуги (параболы)",
-					zap.Float64("dxr", dxr),
-				)
+			if dxr > v.sweepEps {
+				if v.tracer != nil {
+					v.tracer.Info("[f-for-add-bs-for] Новая точка находится СПРАВА от текущей дуги (параболы)",
+						zap.Float64("dxr", dxr),
+					)
+				}
 				if node.right == nil {
 					lNode = node
 					break
 				}
 				node = node.right
 			} else {
-				v.Logger.Info("[f-for-add-bs-for] Новая точка находится МЕЖДУ ДУГАМИ",
-					zap.Float64("dxr", dxr),
-				)
-				if dxl > -1e-9 {
-					v.Logger.Info("[f-for-add-bs-for] Новая точка совпадает с ЛЕВОЙ границей дуги",
-						zap.Float64("dxl", dxl),
+				if v.tracer != nil {
+					v.tracer.Info("[f-for-add-bs-for] Новая точка находится МЕЖДУ ДУГАМИ",
+						zap.Float64("dxr", dxr),
 					)
+				}
+				if dxl > -v.sweepEps {
+					if v.tracer != nil {
+						v.tracer.Info("[f-for-add-bs-for] Новая точка совпадает с ЛЕВОЙ границей дуги",
+							zap.Float64("dxl", dxl),
+						)
+					}
 					lNode = node.previous
 					rNode = node
-				} else if dxr > -1e-9 {
-					v.Logger.Info("[f-for-add-bs-for] Новая точка совпадает с ПРАВОЙ границей дуги",
-						zap.Float64("dxr", dxr),
-					)
+				} else if dxr > -v.sweepEps {
+					if v.tracer != nil {
+						v.tracer.Info("[f-for-add-bs-for] Новая точка совпадает с ПРАВОЙ границей дуги",
+							zap.Float64("dxr", dxr),
+						)
+					}
 					lNode = node
 					rNode = node.next
 				} else {
-					v.Logger.Info("[f-for-add-bs-for] Новая точка находится ВНУТРИ текущей дуги",
-						zap.Float64("dxl", dxl),
-						zap.Float64("dxr", dxr),
-					)
+					if v.tracer != nil {
+						v.tracer.Info("[f-for-add-bs-for] Новая точка находится ВНУТРИ текущей дуги",
+							zap.Float64("dxl", dxl),
+							zap.Float64("dxr", dxr),
+						)
+					}
 					lNode = node
 					rNode = node
 				}
@@ -286,7 +440,9 @@ func (v *Voronoi) addBeachSection(site Vertex) {
 		}
 	}
 
-	v.Logger.Info("[f-add-bs] Позиция для новой дуги найдена")
+	if v.tracer != nil {
+		v.tracer.Info("[f-add-bs] Позиция для новой дуги найдена")
+	}
 	var lArc, rArc *BeachSection
 
 	// достаем левую и правую дуги (если имеются)
@@ -298,8 +454,12 @@ func (v *Voronoi) addBeachSection(site Vertex) {
 		rArc = rNode.value.(*BeachSection)
 	}
 
+	if v.observer != nil {
+		v.observer.OnSiteEvent(site)
+	}
+
 	// создаем новую дугу (параболу)
-	newArc := &BeachSection{site: site}
+	newArc := v.allocArc(site)
 	if lArc == nil {
 		v.beachline.insertSuccessor(nil, newArc)
 	} else {
@@ -316,7 +476,7 @@ func (v *Voronoi) addBeachSection(site Vertex) {
 		// удаляем событие круга, связанное с lArc
 		v.detachCircleEvent(lArc)
 
-		rArc = &BeachSection{site: lArc.site}
+		rArc = v.allocArc(lArc.site)
 		v.beachline.insertSuccessor(newArc.node, rArc)
 
 		lCell := v.cell(lArc.site)
@@ -352,6 +512,9 @@ func (v *Voronoi) addBeachSection(site Vertex) {
 		hb := bx*bx + by*by
 		hc := cx*cx + cy*cy
 		vertex := Vertex{(cy*hb-by*hc)/d + ax, (bx*hc-cx*hb)/d + ay}
+		if v.observer != nil {
+			v.observer.OnVertexCreated(vertex)
+		}
 
 		lCell := v.cell(LeftSite)
 		cell := v.cell(site)
@@ -362,6 +525,8 @@ func (v *Voronoi) addBeachSection(site Vertex) {
 		newArc.edge = v.createEdge(lCell, cell, NO_VERTEX, vertex)
 		rArc.edge = v.createEdge(cell, rCell, NO_VERTEX, vertex)
 
+		v.addTriangle(lCell, cell, rCell)
+
 		v.attachCircleEvent(lArc)
 		v.attachCircleEvent(rArc)
 		return
@@ -406,26 +571,58 @@ func (s *Voronoi) attachCircleEvent(arc *BeachSection) {
 	cx := rSite.X - bx
 	cy := rSite.Y - by
 
-	d := 2 * (ax*cy - ay*cx)
-	if d >= -2e-12 {
+	// событие круга есть, только если дуги сходятся: lSite, cSite, rSite
+	// обходятся по часовой стрелке. Знак проверяется точно, поэтому
+	// коллинеарные тройки не дают ложных событий с центром в бесконечности
+	if orient2d(cSite, lSite, rSite) >= 0 {
 		return
 	}
-
+	// центр окружности относительно cSite. У почти вырожденной тройки во
+	// float64 сокращаются определитель d или числители (вплоть до потери
+	// знака d), тогда центр считается точно. Оценка ошибки - по модулям
+	// слагаемых, как в orient2d
+	d := 2 * (ax*cy - ay*cx)
 	ha := ax*ax + ay*ay
 	hc := cx*cx + cy*cy
 	x := (cy*ha - ay*hc) / d
 	y := (ax*hc - cx*ha) / d
+	permD := 2 * (math.Abs(ax*cy) + math.Abs(ay*cx))
+	errX := math.Abs(cy)*ha + math.Abs(ay)*hc + math.Abs(x)*permD
+	errY := math.Abs(ax)*hc + math.Abs(cx)*ha + math.Abs(y)*permD
+	if !(-d > 0) || 4*machineEps*math.Max(errX, errY) > s.sweepEps*-d {
+		x, y = circumcenterExact(lSite, cSite, rSite)
+	}
+	// событие наступает, когда прямая касается окружности снизу: y + r.
+	// Для центра далеко сверху y + r теряет все знаки, поэтому считаем
+	// через x^2 / (r - y)
+	r := math.Hypot(x, y)
+	sweep := y + r
+	if y < 0 {
+		sweep = x * x / (r - y)
+	}
+	sweep += by
+	// событие после выхода прямой далеко за bbox: его вершина далеко от bbox,
+	// а ребро между двумя такими вершинами во float64 обрезалось бы по неверной
+	// прямой. Пропускаем: все вершины внутри bbox наступают раньше, а
+	// незавершенные ребра достроит connectEdge по биссектрисе сайтов
+	if sweep > s.sweepLimit {
+		return
+	}
 	ycenter := y + by
 
-	circleEventInst := &circleEvent{
+	circleEventInst := s.allocCircleEvent()
+	*circleEventInst = circleEvent{
 		arc:     arc,
 		site:    cSite,
 		x:       x + bx,
-		y:       ycenter + math.Sqrt(x*x+y*y),
+		y:       sweep,
 		ycenter: ycenter,
 	}
 
 	arc.circleEvent = circleEventInst
+	if s.observer != nil {
+		s.observer.OnCircleEventScheduled(Vertex{circleEventInst.x, circleEventInst.ycenter}, circleEventInst.y)
+	}
 
 	var predecessor *rbtNode = nil
 	node := s.circleEvents.root
@@ -453,13 +650,24 @@ func (s *Voronoi) attachCircleEvent(arc *BeachSection) {
 	}
 }
 
+// отменяем событие круга дуги (например, из-за новой дуги между соседями)
 func (v *Voronoi) detachCircleEvent(arc *BeachSection) {
+	if arc.circleEvent != nil && v.observer != nil {
+		circle := arc.circleEvent
+		v.observer.OnCircleEventInvalidated(Vertex{circle.x, circle.ycenter}, circle.y)
+	}
+	v.removeCircleEvent(arc)
+}
+
+func (v *Voronoi) removeCircleEvent(arc *BeachSection) {
 	circle := arc.circleEvent
 	if circle != nil {
 		if circle.node.previous == nil {
 			if circle.node.next != nil {
 				v.firstCircleEvent = circle.node.next.value.(*circleEvent)
-				v.Logger.Info("[f-for-rm-bs-detach-ce] Первое событие круга", zap.Float64("ce_x", v.firstCircleEvent.x), zap.Float64("ce_y", v.firstCircleEvent.y))
+				if v.tracer != nil {
+					v.tracer.Info("[f-for-rm-bs-detach-ce] Первое событие круга", zap.Float64("ce_x", v.firstCircleEvent.x), zap.Float64("ce_y", v.firstCircleEvent.y))
+				}
 			} else {
 				v.firstCircleEvent = nil
 			}
@@ -471,7 +679,10 @@ func (v *Voronoi) detachCircleEvent(arc *BeachSection) {
 
 // Bounding Box
 type BoundingBox struct {
-	Xl, Xr, Yt, Yb float64
+	Xl float64 `json:"xl"`
+	Xr float64 `json:"xr"`
+	Yt float64 `json:"yt"`
+	Yb float64 `json:"yb"`
 }
 
 // Create new Bounding Box
@@ -480,7 +691,7 @@ func NewBoundingBox(xl, xr, yt, yb float64) BoundingBox {
 }
 
 // функция для дополнения всех ребер с bbox (в самом конце, когда еще параболы/дуги остались)
-func connectEdge(edge *edge, bbox BoundingBox) bool {
+func connectEdge(edge *Edge, bbox BoundingBox, sweepEps float64) bool {
 	vb := edge.Vb.Vertex
 	if vb != NO_VERTEX {
 		return true
@@ -503,14 +714,14 @@ func connectEdge(edge *edge, bbox BoundingBox) bool {
 	var fm, fb float64
 
 	// определение наклона ребра fm и смещения fb
-	if !equalEps(ry, ly) {
+	if !equalEps(ry, ly, sweepEps) {
 		// если ry == ly, значит наклона нет (вертикальная линия)
 		fm = (lx - rx) / (ry - ly)
 		fb = fy - fm*fx
 	}
 
 	// вертикальное
-	if equalEps(ry, ly) {
+	if equalEps(ry, ly, sweepEps) {
 		// вышли за границы, не надо соедпинять
 		if fx < xl || fx >= xr {
 			return false
@@ -578,13 +789,23 @@ func connectEdge(edge *edge, bbox BoundingBox) bool {
 
 // обрезаем ребро, если за границы вышло
 // используется алгоритм Лианга-Барски
-func clipEdge(edge *edge, bbox BoundingBox) bool {
-	ax := edge.Va.X
-	ay := edge.Va.Y
-	bx := edge.Vb.X
-	by := edge.Vb.Y
+func clipEdge(edge *Edge, bbox BoundingBox) bool {
+	// отрезок параметризуется от конца, ближнего к bbox: у дальнего конца
+	// (вершина почти коллинеарной тройки) пересечения со сторонами попадают
+	// в t около 1, где во float64 не хватает разрядов
+	va, vb := &edge.Va.Vertex, &edge.Vb.Vertex
+	if bbox.distance(*va) > bbox.distance(*vb) {
+		va, vb = vb, va
+	}
+	ax := va.X
+	ay := va.Y
+	bx := vb.X
+	by := vb.Y
 	t0 := float64(0)
 	t1 := float64(1)
+	// стороны bbox, по которым обрезаны концы (sideNone - не обрезан)
+	side0 := sideNone
+	side1 := sideNone
 	dx := bx - ax
 	dy := by - ay
 
@@ -599,12 +820,14 @@ func clipEdge(edge *edge, bbox BoundingBox) bool {
 			return false
 		} else if r < t1 {
 			t1 = r
+			side1 = sideLeft
 		}
 	} else if dx > 0 {
 		if r > t1 {
 			return false
 		} else if r > t0 {
 			t0 = r
+			side0 = sideLeft
 		}
 	}
 	// вправо
@@ -618,12 +841,14 @@ func clipEdge(edge *edge, bbox BoundingBox) bool {
 			return false
 		} else if r > t0 {
 			t0 = r
+			side0 = sideRight
 		}
 	} else if dx > 0 {
 		if r < t0 {
 			return false
 		} else if r < t1 {
 			t1 = r
+			side1 = sideRight
 		}
 	}
 
@@ -638,12 +863,14 @@ func clipEdge(edge *edge, bbox BoundingBox) bool {
 			return false
 		} else if r < t1 {
 			t1 = r
+			side1 = sideTop
 		}
 	} else if dy > 0 {
 		if r > t1 {
 			return false
 		} else if r > t0 {
 			t0 = r
+			side0 = sideTop
 		}
 	}
 	// вниз
@@ -657,36 +884,88 @@ func clipEdge(edge *edge, bbox BoundingBox) bool {
 			return false
 		} else if r > t0 {
 			t0 = r
+			side0 = sideBottom
 		}
 	} else if dy > 0 {
 		if r < t0 {
 			return false
 		} else if r < t1 {
 			t1 = r
+			side1 = sideBottom
 		}
 	}
 
+	// ребро лишь касается bbox (или вырождено в точку)
+	if t0 >= t1 {
+		return false
+	}
+
 	if t0 > 0 {
-		edge.Va.Vertex = Vertex{ax + t0*dx, ay + t0*dy}
+		*va = bbox.snap(Vertex{ax + t0*dx, ay + t0*dy}, side0)
 	}
 
 	if t1 < 1 {
-		edge.Vb.Vertex = Vertex{ax + t1*dx, ay + t1*dy}
+		*vb = bbox.snap(Vertex{ax + t1*dx, ay + t1*dy}, side1)
 	}
 
 	return true
 }
 
-func equalEps(a, b float64) bool {
-	return math.Abs(a-b) < 1e-9
+// стороны bbox для clipEdge
+const (
+	sideNone = iota
+	sideLeft
+	sideRight
+	sideTop
+	sideBottom
+)
+
+// расстояние от точки до bbox по большей из координат (0 внутри)
+func (b BoundingBox) distance(p Vertex) float64 {
+	return math.Max(math.Max(b.Xl-p.X, p.X-b.Xr), math.Max(math.Max(b.Yt-p.Y, p.Y-b.Yb), 0))
 }
 
-func lessThanEps(a, b float64) bool {
-	return b-a > 1e-9
+// Ставит обрезанный конец ребра точно на сторону bbox. Если второй конец
+// далеко, интерполяция ошибается на единицы последнего разряда его координат,
+// и без этого closeCells не узнал бы сторону
+func (b BoundingBox) snap(p Vertex, side int) Vertex {
+	switch side {
+	case sideLeft:
+		p.X = b.Xl
+	case sideRight:
+		p.X = b.Xr
+	case sideTop:
+		p.Y = b.Yt
+	case sideBottom:
+		p.Y = b.Yb
+	}
+	return p
 }
 
-func moreThanEps(a, b float64) bool {
-	return a-b > 1e-9
+// Допуск прохода прямой: defaultEpsilon, а для bbox меньше 1e3 - пропорционально
+// меньше, чтобы на малых координатах близкие, но разные сайты не сливались
+func sweepTolerance(bbox BoundingBox) float64 {
+	size := math.Max(bbox.Xr-bbox.Xl, bbox.Yb-bbox.Yt)
+	return math.Min(defaultEpsilon, 1e-12*size)
+}
+
+// Позиция прямой, после которой события круга пропускаются: на тысячу
+// размеров bbox ниже bbox. У более далеких вершин ошибка округления координат
+// (1e-16 от расстояния) сравнима с допуском по умолчанию
+func sweepLimitFor(bbox BoundingBox) float64 {
+	return bbox.Yb + 1e3*math.Max(bbox.Xr-bbox.Xl, bbox.Yb-bbox.Yt)
+}
+
+func equalEps(a, b, eps float64) bool {
+	return math.Abs(a-b) < eps
+}
+
+func lessThanEps(a, b, eps float64) bool {
+	return b-a > eps
+}
+
+func moreThanEps(a, b, eps float64) bool {
+	return a-b > eps
 }
 
 // ограничиваем все ребра (отрезки), чтоб за гр bbox не вышли
@@ -696,14 +975,62 @@ func (v *Voronoi) clipEdges(bbox BoundingBox) {
 	for i := len(v.edges) - 1; i >= 0; i-- {
 		edge := v.edges[i]
 
-		if !connectEdge(edge, bbox) || !clipEdge(edge, bbox) || (abs_fn(edge.Va.X-edge.Vb.X) < 1e-9 && abs_fn(edge.Va.Y-edge.Vb.Y) < 1e-9) {
+		removed := !connectEdge(edge, bbox, v.sweepEps) || !clipEdge(edge, bbox) || (abs_fn(edge.Va.X-edge.Vb.X) < v.eps && abs_fn(edge.Va.Y-edge.Vb.Y) < v.eps)
+		if v.observer != nil {
+			v.observer.OnClip(edge, removed)
+		}
+
+		if removed {
 			edge.Va.Vertex = NO_VERTEX
 			edge.Vb.Vertex = NO_VERTEX
 			v.edges[i] = v.edges[len(v.edges)-1]
 			v.edges = v.edges[0 : len(v.edges)-1]
 		}
 	}
-	//v.Logger.Info("[f-for-rm-bs-detach-ce] Первое событие круга", zap.Float64("ce_x", v.firstCircleEvent.x), zap.Float64("ce_y", v.firstCircleEvent.y))
+}
+
+// Склеивает вершины ребер, которые ближе v.eps друг к другу. В вырожденных
+// случаях (четыре и больше сайтов на одной окружности) одна вершина Вороного
+// может прийти из нескольких событий круга с разницей в последних битах, и
+// между копиями остается ребро нулевой длины. После склейки у такого ребра
+// совпадают концы, и clipEdges его удаляет
+func (v *Voronoi) weldVertices() {
+	refs := v.vertexRefs[:0]
+	for _, edge := range v.edges {
+		if edge.Va.Vertex != NO_VERTEX {
+			refs = append(refs, &edge.Va)
+		}
+		if edge.Vb.Vertex != NO_VERTEX {
+			refs = append(refs, &edge.Vb)
+		}
+	}
+	slices.SortFunc(refs, func(a, b *EdgeVertex) int {
+		if c := cmp.Compare(a.X, b.X); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Y, b.Y)
+	})
+
+	// жадная кластеризация: первая вершина кластера становится его представителем
+	welded := v.welded[:0]
+	welded = slices.Grow(welded, len(refs))[:len(refs)]
+	clear(welded)
+	for i, ref := range refs {
+		if welded[i] {
+			continue
+		}
+		p := ref.Vertex
+		for j := i + 1; j < len(refs) && refs[j].X-p.X < v.eps; j++ {
+			if !welded[j] && math.Abs(refs[j].Y-p.Y) < v.eps {
+				refs[j].Vertex = p
+				welded[j] = true
+			}
+		}
+	}
+
+	clear(refs)
+	v.vertexRefs = refs[:0]
+	v.welded = welded[:0]
 }
 
 // закрываем ячейки, гарантируя, что каждая ячейка внутри bbox
@@ -712,61 +1039,76 @@ func (v *Voronoi) closeCells(bbox BoundingBox) {
 	right := bbox.Xr
 	top := bbox.Yt
 	bottom := bbox.Yb
+	eps := v.eps
 	cells := v.cells
 
 	for _, cell := range cells {
-		// Пропускаем ячейки без рёбер
+		// ячейка без рёбер внутри bbox либо целиком снаружи, либо (если ее сайт
+		// внутри) занимает весь bbox - тогда обходим его по сторонам
 		if cell.prepare() == 0 {
+			if bbox.contains(cell.site) {
+				v.closeLoneCell(cell, bbox)
+			}
 			continue
 		}
 
 		halfEdges := cell.halfEdges
 		numHalfEdges := len(halfEdges)
+		// на каждый зазор приходится одно граничное полуребро плюс по одному
+		// на каждый пройденный угол bbox. Больше - конец ребра не лежит ни на
+		// одной стороне, и цикл вставлял бы полуребра бесконечно
+		maxHalfEdges := 2*numHalfEdges + 4
 
 		currentEdgeIdx := 0
 		for currentEdgeIdx < numHalfEdges {
 			nextEdgeIdx := (currentEdgeIdx + 1) % numHalfEdges
-			endPoint := halfEdges[currentEdgeIdx].endPoint()
-			startPoint := halfEdges[nextEdgeIdx].startPoint()
+			endPoint := halfEdges[currentEdgeIdx].EndPoint()
+			startPoint := halfEdges[nextEdgeIdx].StartPoint()
 
 			// Проверка на наличие зазора между текущим и следующим полурёбрами
-			if math.Abs(endPoint.X-startPoint.X) >= 1e-9 || math.Abs(endPoint.Y-startPoint.Y) >= 1e-9 {
+			if math.Abs(endPoint.X-startPoint.X) >= eps || math.Abs(endPoint.Y-startPoint.Y) >= eps {
 				startVertex := endPoint
 				endVertex := endPoint
+				// если следующее полуребро начинается на той же стороне bbox, берем его
+				// начало как есть, без привязки к стороне: иначе вершины разойдутся
+				// в последних битах и граф диаграммы (Diagram.Graph) разорвется
 
 				// Идём вниз вдоль левой границы
-				if equalEps(endPoint.X, left) && lessThanEps(endPoint.Y, bottom) {
-					if equalEps(startPoint.X, left) {
-						endVertex = Vertex{left, startPoint.Y}
+				if equalEps(endPoint.X, left, eps) && lessThanEps(endPoint.Y, bottom, eps) {
+					if equalEps(startPoint.X, left, eps) {
+						endVertex = startPoint
 					} else {
 						endVertex = Vertex{left, bottom}
 					}
 
 					// Идём вправо вдоль нижней границы
-				} else if equalEps(endPoint.Y, bottom) && lessThanEps(endPoint.X, right) {
-					if equalEps(startPoint.Y, bottom) {
-						endVertex = Vertex{startPoint.X, bottom}
+				} else if equalEps(endPoint.Y, bottom, eps) && lessThanEps(endPoint.X, right, eps) {
+					if equalEps(startPoint.Y, bottom, eps) {
+						endVertex = startPoint
 					} else {
 						endVertex = Vertex{right, bottom}
 					}
 
 					// Идём вверх вдоль правой границы
-				} else if equalEps(endPoint.X, right) && moreThanEps(endPoint.Y, top) {
-					if equalEps(startPoint.X, right) {
-						endVertex = Vertex{right, startPoint.Y}
+				} else if equalEps(endPoint.X, right, eps) && moreThanEps(endPoint.Y, top, eps) {
+					if equalEps(startPoint.X, right, eps) {
+						endVertex = startPoint
 					} else {
 						endVertex = Vertex{right, top}
 					}
 
 					// Идём влево вдоль верхней границы
-				} else if equalEps(endPoint.Y, top) && moreThanEps(endPoint.X, left) {
-					if equalEps(startPoint.Y, top) {
-						endVertex = Vertex{startPoint.X, top}
+				} else if equalEps(endPoint.Y, top, eps) && moreThanEps(endPoint.X, left, eps) {
+					if equalEps(startPoint.Y, top, eps) {
+						endVertex = startPoint
 					} else {
 						endVertex = Vertex{left, top}
 					}
 				}
 
+				if numHalfEdges == maxHalfEdges {
+					panic(&SiteError{Index: cell.index, Site: cell.site, Err: ErrCellNotClosed})
+				}
 				newEdge := v.createBorderEdge(cell, startVertex, endVertex)
 				cell.halfEdges = append(cell.halfEdges, nil)
 				halfEdges = cell.halfEdges
@@ -774,31 +1116,24 @@ func (v *Voronoi) closeCells(bbox BoundingBox) {
 
 				// Вставляем новое полуребро для замыкания ячейки
 				copy(halfEdges[currentEdgeIdx+2:], halfEdges[currentEdgeIdx+1:len(halfEdges)-1])
-				halfEdges[currentEdgeIdx+1] = newHalfEdge(newEdge, cell, nil)
+				halfEdges[currentEdgeIdx+1] = v.allocHalfEdge(newEdge, cell, nil)
 			}
 			currentEdgeIdx++
 		}
 	}
 }
 
-func (v *Voronoi) gatherVertexEdges() {
-	vertexEdgeMap := make(map[Vertex][]*edge)
-
-	for _, edge := range v.edges {
-		vertexEdgeMap[edge.Va.Vertex] = append(
-			vertexEdgeMap[edge.Va.Vertex], edge)
-		vertexEdgeMap[edge.Vb.Vertex] = append(
-			vertexEdgeMap[edge.Vb.Vertex], edge)
+// обводит ячейку вдоль bbox в том же направлении, что и closeCells:
+// вниз по левой стороне, вправо по нижней, вверх по правой, влево по верхней
+func (v *Voronoi) closeLoneCell(cell *Cell, bbox BoundingBox) {
+	corners := [4]Vertex{
+		{bbox.Xl, bbox.Yt},
+		{bbox.Xl, bbox.Yb},
+		{bbox.Xr, bbox.Yb},
+		{bbox.Xr, bbox.Yt},
 	}
-
-	for vertex, edgeSlice := range vertexEdgeMap {
-		for _, edge := range edgeSlice {
-			if vertex == edge.Va.Vertex {
-				edge.Va.Edges = edgeSlice
-			}
-			if vertex == edge.Vb.Vertex {
-				edge.Vb.Edges = edgeSlice
-			}
-		}
+	for i, a := range corners {
+		edge := v.createBorderEdge(cell, a, corners[(i+1)%len(corners)])
+		cell.halfEdges = append(cell.halfEdges, v.allocHalfEdge(edge, cell, nil))
 	}
 }
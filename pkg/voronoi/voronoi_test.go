@@ -0,0 +1,973 @@
+package voronoi
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// относительная погрешность проверок
+const testEps = 1e-6
+
+func randomSites(r *rand.Rand, n int, bbox BoundingBox) []Vertex {
+	sites := make([]Vertex, n)
+	for i := range sites {
+		sites[i] = Vertex{
+			bbox.Xl + r.Float64()*(bbox.Xr-bbox.Xl),
+			bbox.Yt + r.Float64()*(bbox.Yb-bbox.Yt),
+		}
+	}
+	return sites
+}
+
+func gridSites(rows, cols int, bbox BoundingBox) []Vertex {
+	dx := (bbox.Xr - bbox.Xl) / float64(cols)
+	dy := (bbox.Yb - bbox.Yt) / float64(rows)
+	sites := make([]Vertex, 0, rows*cols)
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			sites = append(sites, Vertex{bbox.Xl + dx/2 + float64(j)*dx, bbox.Yt + dy/2 + float64(i)*dy})
+		}
+	}
+	return sites
+}
+
+// Проверяет свойства замкнутой диаграммы:
+//   - ячейки разбивают bbox: сумма площадей равна площади bbox;
+//   - каждая ячейка содержит свой сайт;
+//   - концы каждого внутреннего ребра равноудалены от сайтов по обе стороны;
+//   - каждая внутренняя вершина равноудалена от сайтов всех (не менее трех)
+//     сходящихся в ней ячеек, и ни один сайт не лежит к ней ближе
+func checkDiagram(t testing.TB, d *Diagram) {
+	t.Helper()
+	bbox := d.BBox
+	scale := math.Max(bbox.Xr-bbox.Xl, bbox.Yb-bbox.Yt)
+	tol := testEps * scale
+	// вершины ближе mergeTol должны быть склеены
+	mergeTol := 1e-12 * scale
+
+	area := (bbox.Xr - bbox.Xl) * (bbox.Yb - bbox.Yt)
+	var total float64
+	for i, cell := range d.Cells {
+		total += cell.Area()
+		if !polygonContains(cell.Polygon(), cell.Site(), tol) {
+			t.Fatalf("cell %d does not contain its site %v", i, cell.Site())
+		}
+	}
+	if math.Abs(total-area) > testEps*area {
+		t.Fatalf("cells area %v, bbox area %v", total, area)
+	}
+
+	for _, e := range d.Edges {
+		a, b := e.Endpoints()
+		if a == b {
+			t.Fatalf("zero-length edge at %v", a)
+		}
+		if e.IsBorder() {
+			continue
+		}
+		for _, p := range []Vertex{a, b} {
+			dl := math.Sqrt(sqDist(p, e.LeftCell.Site()))
+			dr := math.Sqrt(sqDist(p, e.RightCell.Site()))
+			if math.Abs(dl-dr) > tol {
+				t.Fatalf("edge endpoint %v: %v from left site, %v from right", p, dl, dr)
+			}
+		}
+	}
+
+	for _, cell := range d.Cells {
+		for _, p := range cell.Polygon() {
+			if onBorder(p, bbox, tol) {
+				continue
+			}
+			sites := []Vertex{cell.Site()}
+			for _, n := range cell.Neighbors() {
+				for _, q := range n.Polygon() {
+					if q == p {
+						sites = append(sites, n.Site())
+						break
+					}
+					// копии одной вершины должны быть склеены
+					if math.Abs(q.X-p.X) < mergeTol && math.Abs(q.Y-p.Y) < mergeTol {
+						t.Fatalf("vertices %v and %v are not merged", p, q)
+					}
+				}
+			}
+			if len(sites) < 3 {
+				t.Fatalf("vertex %v is shared by %d cells", p, len(sites))
+			}
+			r := math.Sqrt(sqDist(p, sites[0]))
+			for _, s := range sites[1:] {
+				if dist := math.Sqrt(sqDist(p, s)); math.Abs(dist-r) > tol {
+					t.Fatalf("vertex %v: %v from %v, %v from %v", p, r, sites[0], dist, s)
+				}
+			}
+			for _, other := range d.Cells {
+				if dist := math.Sqrt(sqDist(p, other.Site())); dist < r-tol {
+					t.Fatalf("vertex %v: site %v at %v is closer than %v", p, other.Site(), dist, r)
+				}
+			}
+		}
+	}
+}
+
+func onBorder(p Vertex, bbox BoundingBox, tol float64) bool {
+	return math.Abs(p.X-bbox.Xl) <= tol || math.Abs(p.X-bbox.Xr) <= tol ||
+		math.Abs(p.Y-bbox.Yt) <= tol || math.Abs(p.Y-bbox.Yb) <= tol
+}
+
+// точка внутри выпуклого многоугольника или на его границе
+func polygonContains(polygon []Vertex, p Vertex, tol float64) bool {
+	if len(polygon) < 3 {
+		return false
+	}
+	sign := 0.0
+	for i, a := range polygon {
+		b := polygon[(i+1)%len(polygon)]
+		cross := (b.X-a.X)*(p.Y-a.Y) - (b.Y-a.Y)*(p.X-a.X)
+		if math.Abs(cross) <= tol*math.Hypot(b.X-a.X, b.Y-a.Y) {
+			continue
+		}
+		if sign == 0 {
+			sign = cross
+		} else if (sign > 0) != (cross > 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func mustBuild(t testing.TB, sites []Vertex, bbox BoundingBox) *Diagram {
+	t.Helper()
+	d, err := Build(sites, bbox, Options{CloseCells: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestDiagramRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	bbox := NewBoundingBox(0, 1000, 0, 500)
+	for k := 0; k < 100; k++ {
+		checkDiagram(t, mustBuild(t, randomSites(r, 2+r.Intn(300), bbox), bbox))
+	}
+}
+
+func TestDiagramGrid(t *testing.T) {
+	bbox := NewBoundingBox(0, 1000, 0, 500)
+	for _, size := range [][2]int{{1, 2}, {2, 2}, {3, 3}, {3, 4}, {4, 4}, {5, 10}, {20, 20}} {
+		checkDiagram(t, mustBuild(t, gridSites(size[0], size[1], bbox), bbox))
+	}
+}
+
+func TestDiagramDegenerate(t *testing.T) {
+	bbox := NewBoundingBox(-10, 10, -10, 10)
+	cases := map[string][]Vertex{
+		"single":     {{0, 0}},
+		"horizontal": {{-5, 0}, {0, 0}, {5, 0}, {7, 0}},
+		"vertical":   {{0, -5}, {0, 0}, {0, 5}, {0, 7}},
+		"diagonal":   {{-6, -6}, {-2, -2}, {3, 3}, {8, 8}},
+		"cocircular": {{5, 0}, {0, 5}, {-5, 0}, {0, -5}, {3, 4}, {-4, 3}, {-3, -4}, {4, -3}},
+		"duplicates": {{1, 1}, {1, 1}, {-3, 2}, {1, 1}, {-3, 2}, {4, -4}},
+	}
+	for name, sites := range cases {
+		t.Run(name, func(t *testing.T) {
+			checkDiagram(t, mustBuild(t, sites, bbox))
+		})
+	}
+}
+
+// подмножества целочисленной решетки: много сайтов на одной горизонтали
+// и много четверок на одной окружности
+func TestDiagramLattice(t *testing.T) {
+	r := rand.New(rand.NewSource(4))
+	for _, scale := range []float64{1, 1000, 1e6} {
+		bbox := NewBoundingBox(0, 16*scale, 0, 16*scale)
+		for k := 0; k < 200; k++ {
+			var sites []Vertex
+			keep := 0.2 + 0.8*r.Float64()
+			for i := 0; i < 16; i++ {
+				for j := 0; j < 16; j++ {
+					if r.Float64() < keep {
+						sites = append(sites, Vertex{(float64(j) + 0.5) * scale, (float64(i) + 0.5) * scale})
+					}
+				}
+			}
+			if len(sites) == 0 {
+				continue
+			}
+			checkDiagram(t, mustBuild(t, sites, bbox))
+		}
+	}
+}
+
+// четыре сайта на одной окружности, координаты которых не представимы точно:
+// события круга дают копии вершины, которые должны склеиться в одну
+func TestDiagramMergesCocircularVertex(t *testing.T) {
+	bbox := NewBoundingBox(0, 1000, 0, 1000)
+	unit := 1000.0 / 255
+	sites := []Vertex{{24 * unit, 14 * unit}, {17 * unit, 15 * unit}, {25 * unit, 15 * unit}, {21 * unit, 23 * unit}}
+	d := mustBuild(t, sites, bbox)
+	checkDiagram(t, d)
+
+	inner := 0
+	for _, e := range d.Edges {
+		if !e.IsBorder() {
+			inner++
+		}
+	}
+	if inner != 4 {
+		t.Fatalf("got %d inner edges, want 4 meeting at one vertex", inner)
+	}
+}
+
+// почти коллинеарные сайты с непредставимыми координатами: центр окружности
+// далеко за bbox, и во float64 без точного пересчета он сдвигал биссектрисы
+func TestDiagramNearCollinear(t *testing.T) {
+	bbox := NewBoundingBox(0, 1000, 0, 1000)
+	for _, n := range []int{4, 8} {
+		var sites []Vertex
+		for i := 0; i < n; i++ {
+			sites = append(sites, Vertex{float64(2*i+1) / 255 * 1000, float64(2*i+2) / 255 * 1000})
+		}
+
+		open, err := Build(sites, bbox, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(open.Edges) != n-1 {
+			t.Fatalf("n=%d: got %d edges, want %d parallel bisectors", n, len(open.Edges), n-1)
+		}
+		for _, e := range open.Edges {
+			for _, p := range []Vertex{e.Va.Vertex, e.Vb.Vertex} {
+				dl := math.Sqrt(sqDist(p, e.LeftCell.Site()))
+				dr := math.Sqrt(sqDist(p, e.RightCell.Site()))
+				if math.Abs(dl-dr) > 1e-6 {
+					t.Fatalf("n=%d: edge endpoint %v: %v from left site, %v from right", n, p, dl, dr)
+				}
+			}
+		}
+
+		d := mustBuild(t, sites, bbox)
+		if len(d.Cells) != n {
+			t.Fatalf("n=%d: got %d cells", n, len(d.Cells))
+		}
+		checkDiagram(t, d)
+	}
+}
+
+// ряды сайтов, Y которых различаются на единицы последнего разряда (так
+// выходит после релаксации сетки): дуги с фокусом почти на прямой и центры
+// окружностей далеко над bbox
+func TestDiagramNearHorizontalRows(t *testing.T) {
+	bbox := NewBoundingBox(0, 1000, 0, 1000)
+	sites := []Vertex{
+		{100.00000000000001, 166.6666666666667}, {300, 166.6666666666667}, {500, 166.66666666666669},
+		{700.0000000000001, 166.66666666666669}, {899.9999999999999, 166.66666666666669},
+		{99.99999999999999, 499.99999999999994}, {300, 500.00000000000006}, {499.99999999999994, 500},
+		{705.0847457627119, 531.5254237288136}, {906.7549414699674, 623.1894070236037},
+		{100.00000000000001, 833.3333333333331}, {300, 833.3333333333331}, {623.6379991395833, 857.0831866713597},
+	}
+	checkDiagram(t, mustBuild(t, sites, bbox))
+
+	r := rand.New(rand.NewSource(19))
+	for k := 0; k < 300; k++ {
+		y := bbox.Yt + r.Float64()*(bbox.Yb-bbox.Yt)
+		var sites []Vertex
+		for i := 0; i < 3+r.Intn(10); i++ {
+			sites = append(sites, Vertex{r.Float64() * 1000, math.Nextafter(y, y+float64(r.Intn(3)-1))})
+		}
+		sites = append(sites, randomSites(r, r.Intn(5), bbox)...)
+		checkDiagram(t, mustBuild(t, sites, bbox))
+	}
+}
+
+func TestDiagramDuplicates(t *testing.T) {
+	bbox := NewBoundingBox(-10, 10, -10, 10)
+	sites := []Vertex{{1, 1}, {1, 1}, {-3, 2}, {1, 1}, {-3, 2}, {4, -4}}
+	d := mustBuild(t, sites, bbox)
+	if len(d.Cells) != 3 {
+		t.Fatalf("got %d cells, want 3", len(d.Cells))
+	}
+	if len(d.Duplicates) != 3 {
+		t.Fatalf("got %d duplicates, want 3", len(d.Duplicates))
+	}
+	// сайты идут по Y: сначала (1, 1) с позиций 0, 1, 3, потом (-3, 2) с позиций 2, 4
+	if want := []int{1, 3, 4}; !slices.Equal(d.DuplicateIndices, want) {
+		t.Fatalf("got duplicate indices %v, want %v", d.DuplicateIndices, want)
+	}
+	for k, i := range d.DuplicateIndices {
+		if d.Duplicates[k] != sites[i] || d.CellOf(i).Index() == i {
+			t.Fatalf("duplicate %d: %v at sites[%d] = %v", k, d.Duplicates[k], i, sites[i])
+		}
+	}
+}
+
+func TestDelaunayEuler(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	bbox := NewBoundingBox(0, 100, 0, 100)
+	for k := 0; k < 50; k++ {
+		d := mustBuild(t, randomSites(r, 3+r.Intn(200), bbox), bbox)
+		tr := d.Delaunay()
+		// для триангуляции n точек с h точками на выпуклой оболочке:
+		// T = 2n - 2 - h, E = 3n - 3 - h
+		n := len(d.Cells)
+		h := 2*n - 2 - len(tr.Triangles)
+		if len(tr.Edges) != 3*n-3-h {
+			t.Fatalf("n=%d: %d triangles, %d edges", n, len(tr.Triangles), len(tr.Edges))
+		}
+	}
+}
+
+// релаксация сближает сайты с центрами масс их ячеек и не выводит их из bbox
+func TestRelax(t *testing.T) {
+	bbox := NewBoundingBox(0, 1000, 0, 500)
+	sites := randomSites(rand.New(rand.NewSource(17)), 100, bbox)
+	input := slices.Clone(sites)
+
+	// средний сдвиг сайта к центру масс его ячейки
+	meanShift := func(sites []Vertex) float64 {
+		d := mustBuild(t, sites, bbox)
+		var sum float64
+		for i, site := range sites {
+			c := d.CellOf(i).Centroid()
+			sum += math.Hypot(c.X-site.X, c.Y-site.Y)
+		}
+		return sum / float64(len(sites))
+	}
+
+	before := meanShift(sites)
+	iterations := 0
+	relaxed, err := Relax(sites, bbox, 30, RelaxOptions{
+		OnIteration: func(iteration int, cur []Vertex, _ float64) bool {
+			iterations = iteration
+			for _, p := range cur {
+				if !bbox.contains(p) {
+					t.Fatalf("iteration %d: site %v is outside bbox", iteration, p)
+				}
+			}
+			return true
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if iterations != 30 {
+		t.Fatalf("ran %d iterations, want 30", iterations)
+	}
+	if after := meanShift(relaxed); after > before/10 {
+		t.Fatalf("mean centroid distance %v -> %v, want at least 10x smaller", before, after)
+	}
+	if len(relaxed) != len(sites) || !slices.Equal(sites, input) {
+		t.Fatal("Relax must keep the input and return one site per input site")
+	}
+
+	// с допуском релаксация останавливается раньше
+	iterations = 0
+	_, err = Relax(sites, bbox, 1000, RelaxOptions{
+		Tolerance:   1e-2,
+		OnIteration: func(iteration int, _ []Vertex, _ float64) bool { iterations = iteration; return true },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if iterations == 0 || iterations == 1000 {
+		t.Fatalf("ran %d iterations with tolerance", iterations)
+	}
+}
+
+// релаксация сетки: центры масс симметричных ячеек дают сайты, Y которых
+// различаются в последних разрядах. Раскладка та же, что у формы в cmd/app:
+// последняя строка сетки может быть неполной
+func TestRelaxGrid(t *testing.T) {
+	for _, c := range []struct{ n, width, height int }{{13, 1000, 1000}, {50, 1000, 1000}, {50, 700, 300}} {
+		bbox := NewBoundingBox(0, float64(c.width), 0, float64(c.height))
+		rows := int(math.Sqrt(float64(c.n)))
+		cols := (c.n + rows - 1) / rows
+		sites := gridSites(rows, cols, bbox)[:c.n]
+
+		_, err := Relax(sites, bbox, 20, RelaxOptions{
+			Tolerance: 1e-3,
+			OnIteration: func(_ int, cur []Vertex, _ float64) bool {
+				checkDiagram(t, mustBuild(t, cur, bbox))
+				return true
+			},
+		})
+		if err != nil {
+			t.Fatalf("%+v: %v", c, err)
+		}
+	}
+}
+
+// по снимку на каждое событие, последний снимок совпадает с итоговой диаграммой
+func TestRecordSteps(t *testing.T) {
+	r := rand.New(rand.NewSource(18))
+	bbox := NewBoundingBox(0, 1000, 0, 500)
+	for k := 0; k < 20; k++ {
+		sites := randomSites(r, 2+r.Intn(60), bbox)
+		d, err := Build(sites, bbox, Options{RecordSteps: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		counts := make(map[string]int)
+		for i, step := range d.Steps {
+			counts[step.Event]++
+			if i > 0 && step.Event != EventDone && step.Sweep < d.Steps[i-1].Sweep {
+				t.Fatalf("step %d: sweep goes back from %v to %v", i, d.Steps[i-1].Sweep, step.Sweep)
+			}
+		}
+		// событие круга схлопывает дугу и дает один треугольник Делоне
+		if counts[EventSite] != len(d.Cells) || counts[EventCircle] != len(d.Delaunay().Triangles) || counts[EventDone] != 1 {
+			t.Fatalf("events %v for %d cells and %d triangles", counts, len(d.Cells), len(d.Delaunay().Triangles))
+		}
+
+		last := d.Steps[len(d.Steps)-1]
+		if last.Event != EventDone {
+			t.Fatalf("last step is %q", last.Event)
+		}
+		want, err := Build(sites, bbox, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(last.Edges) != len(want.Edges) {
+			t.Fatalf("last step has %d edges, Build has %d", len(last.Edges), len(want.Edges))
+		}
+		for i, e := range want.Edges {
+			if last.Edges[i] != [2]Vertex{e.Va.Vertex, e.Vb.Vertex} {
+				t.Fatalf("edge %d: step %v, Build %v-%v", i, last.Edges[i], e.Va.Vertex, e.Vb.Vertex)
+			}
+		}
+	}
+}
+
+// Locator находит ближайший сайт, как перебор, в том числе на ребрах и в вершинах
+func TestLocatorMatchesBruteForce(t *testing.T) {
+	r := rand.New(rand.NewSource(19))
+	bbox := NewBoundingBox(0, 1000, 0, 500)
+	for k := 0; k < 20; k++ {
+		sites := randomSites(r, 1+r.Intn(200), bbox)
+		d := mustBuild(t, sites, bbox)
+		l := NewLocator(d)
+
+		points := randomSites(r, 200, bbox)
+		for _, e := range d.Edges {
+			points = append(points, e.Va.Vertex, Vertex{(e.Va.X + e.Vb.X) / 2, (e.Va.Y + e.Vb.Y) / 2})
+		}
+		points = append(points, Vertex{bbox.Xl, bbox.Yt}, Vertex{bbox.Xr, bbox.Yb})
+
+		for _, p := range points {
+			// обрезанные вершины могут выйти за bbox в последних битах
+			if !bbox.contains(p) {
+				continue
+			}
+			cell := l.Locate(p)
+			if cell == nil {
+				t.Fatalf("point %v inside bbox is not located", p)
+			}
+			best := math.Inf(1)
+			for _, site := range sites {
+				best = math.Min(best, sqDist(p, site))
+			}
+			// на ребре подходит любой из равноудаленных сайтов
+			if got := sqDist(p, cell.Site()); got > best+testEps*(1+best) {
+				t.Fatalf("point %v: located site %v at %v, nearest at %v", p, cell.Site(), got, best)
+			}
+			if d.CellOf(cell.Index()) != cell {
+				t.Fatalf("point %v: cell index %d does not map back", p, cell.Index())
+			}
+		}
+
+		for _, p := range []Vertex{{-1, 250}, {1001, 250}, {500, -1e-9}, {500, 600}, {math.NaN(), 1}} {
+			if cell := l.Locate(p); cell != nil {
+				t.Fatalf("point %v outside bbox located in %v", p, cell.Site())
+			}
+		}
+	}
+}
+
+// у квадратной решетки все ячейки - одинаковые квадраты с центром в сайте
+func TestStatsGrid(t *testing.T) {
+	bbox := NewBoundingBox(0, 400, 0, 400)
+	d := mustBuild(t, gridSites(4, 4, bbox), bbox)
+	st := d.Stats()
+
+	near := func(got, want float64) bool { return math.Abs(got-want) <= testEps*math.Max(1, math.Abs(want)) }
+	if st.Cells != 16 || !near(st.TotalArea, 400*400) || !near(st.MinArea, 100*100) || !near(st.MaxArea, 100*100) ||
+		!near(st.MeanArea, 100*100) || !near(st.StdDevArea, 0) || !near(st.MeanPerimeter, 400) || !near(st.MeanCompactness, math.Pi/4) {
+		t.Fatalf("stats %+v", st)
+	}
+	for _, cell := range d.Cells {
+		site := cell.Site()
+		if c := cell.Centroid(); !near(c.X, site.X) || !near(c.Y, site.Y) {
+			t.Fatalf("cell %v: centroid %v", site, c)
+		}
+		b := cell.Bounds()
+		if !near(b.Xl, site.X-50) || !near(b.Xr, site.X+50) || !near(b.Yt, site.Y-50) || !near(b.Yb, site.Y+50) {
+			t.Fatalf("cell %v: bounds %+v", site, b)
+		}
+	}
+}
+
+// единственная ячейка замыкается по bbox и занимает его целиком
+func TestStatsLoneCell(t *testing.T) {
+	bbox := NewBoundingBox(0, 400, 0, 200)
+	d := mustBuild(t, []Vertex{{100, 50}}, bbox)
+	cell := d.Cells[0]
+	if len(cell.HalfEdges()) != 4 {
+		t.Fatalf("lone cell has %d half-edges, want 4", len(cell.HalfEdges()))
+	}
+	if got := cell.Area(); math.Abs(got-400*200) > testEps*400*200 {
+		t.Fatalf("area %v, want %v", got, 400*200)
+	}
+	if c := cell.Centroid(); c != (Vertex{200, 100}) {
+		t.Fatalf("centroid %v", c)
+	}
+	if b := cell.Bounds(); b != bbox {
+		t.Fatalf("bounds %+v, want %+v", b, bbox)
+	}
+}
+
+// статистика случайной диаграммы совпадает с подсчетом по ячейкам
+func TestStatsRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(21))
+	bbox := NewBoundingBox(0, 1000, 0, 500)
+	for k := 0; k < 10; k++ {
+		d := mustBuild(t, randomSites(r, 2+r.Intn(300), bbox), bbox)
+		st := d.Stats()
+
+		var sum, sumSq, perimeter float64
+		for _, cell := range d.Cells {
+			area := cell.Area()
+			sum += area
+			sumSq += area * area
+			perimeter += cell.Perimeter()
+			if c := cell.Compactness(); c <= 0 || c > 1 {
+				t.Fatalf("cell %v: compactness %v", cell.Site(), c)
+			}
+		}
+		n := float64(len(d.Cells))
+		mean := sum / n
+		std := math.Sqrt(math.Max(0, sumSq/n-mean*mean))
+
+		area := (bbox.Xr - bbox.Xl) * (bbox.Yb - bbox.Yt)
+		if math.Abs(st.TotalArea-area) > testEps*area || math.Abs(st.MeanArea-mean) > testEps*mean ||
+			math.Abs(st.StdDevArea-std) > testEps*mean || math.Abs(st.MeanPerimeter-perimeter/n) > testEps*perimeter {
+			t.Fatalf("stats %+v, want total %v, mean %v, std %v", st, area, mean, std)
+		}
+		if st.MinArea > st.MeanArea || st.MeanArea > st.MaxArea {
+			t.Fatalf("stats %+v", st)
+		}
+	}
+
+	if st := (&Diagram{}).Stats(); st != (Stats{}) {
+		t.Fatalf("empty diagram stats %+v", st)
+	}
+}
+
+// наблюдатель, записывающий события прохода
+type recordingObserver struct {
+	// события точки и круга по порядку: Y сайта или прямой сканирования
+	events    []string
+	sweeps    []float64
+	sites     []Vertex
+	scheduled int
+	cancelled int
+	edges     int
+	vertices  int
+	removed   int
+	// центры исполненных событий круга
+	centers map[Vertex]bool
+	// вершины, созданные событием точки на точке излома
+	siteVertices int
+}
+
+func (o *recordingObserver) OnSiteEvent(site Vertex) {
+	o.events = append(o.events, EventSite)
+	o.sweeps = append(o.sweeps, site.Y)
+	o.sites = append(o.sites, site)
+}
+
+func (o *recordingObserver) OnCircleEvent(center Vertex, sweep float64) {
+	o.events = append(o.events, EventCircle)
+	o.sweeps = append(o.sweeps, sweep)
+	o.centers[center] = true
+}
+
+func (o *recordingObserver) OnCircleEventScheduled(Vertex, float64)   { o.scheduled++ }
+func (o *recordingObserver) OnCircleEventInvalidated(Vertex, float64) { o.cancelled++ }
+func (o *recordingObserver) OnEdgeCreated(*Edge)                      { o.edges++ }
+func (o *recordingObserver) OnVertexCreated(Vertex) {
+	o.vertices++
+	if o.events[len(o.events)-1] == EventSite {
+		o.siteVertices++
+	}
+}
+
+func (o *recordingObserver) OnClip(_ *Edge, removed bool) {
+	if removed {
+		o.removed++
+	}
+}
+
+// наблюдатель видит события в порядке прохода прямой, и их число сходится с диаграммой.
+// На решетке и окружности в одной вершине сходится больше трех ячеек
+func TestObserver(t *testing.T) {
+	r := rand.New(rand.NewSource(22))
+	bbox := NewBoundingBox(0, 1000, 0, 500)
+	var cases [][]Vertex
+	for k := 0; k < 20; k++ {
+		cases = append(cases, randomSites(r, 2+r.Intn(200), bbox))
+	}
+	cases = append(cases, gridSites(5, 5, bbox), gridSites(8, 3, bbox))
+	var circle []Vertex
+	for k := 0; k < 12; k++ {
+		angle := float64(k) * math.Pi / 6
+		circle = append(circle, Vertex{500 + 200*math.Cos(angle), 250 + 200*math.Sin(angle)})
+	}
+	cases = append(cases, circle, append(circle, Vertex{500, 250}))
+
+	for _, sites := range cases {
+		obs := &recordingObserver{centers: make(map[Vertex]bool)}
+		d, err := Build(sites, bbox, Options{CloseCells: true, Observer: obs})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 1; i < len(obs.sweeps); i++ {
+			if obs.sweeps[i] < obs.sweeps[i-1] {
+				t.Fatalf("event %d (%s) at %v after %v", i, obs.events[i], obs.sweeps[i], obs.sweeps[i-1])
+			}
+		}
+		// ячейки идут в порядке событий точки
+		if len(obs.sites) != len(d.Cells) {
+			t.Fatalf("%d site events for %d cells", len(obs.sites), len(d.Cells))
+		}
+		for i, cell := range d.Cells {
+			if obs.sites[i] != cell.Site() {
+				t.Fatalf("site event %d is %v, cell %v", i, obs.sites[i], cell.Site())
+			}
+		}
+
+		circles := len(obs.events) - len(obs.sites)
+		if triangles := len(d.Delaunay().Triangles); circles+obs.siteVertices != triangles {
+			t.Fatalf("%d circle events and %d site event vertices for %d triangles", circles, obs.siteVertices, triangles)
+		}
+		// к концу прохода каждое запланированное событие исполнено или отменено
+		if obs.scheduled != circles+obs.cancelled {
+			t.Fatalf("%d scheduled, %d executed, %d cancelled", obs.scheduled, circles, obs.cancelled)
+		}
+		if obs.edges-obs.removed != len(d.Edges) {
+			t.Fatalf("%d edges created, %d removed, diagram has %d", obs.edges, obs.removed, len(d.Edges))
+		}
+		// на вершину - одно OnVertexCreated, сколько бы дуг в ней ни исчезло
+		if obs.vertices-obs.siteVertices != len(obs.centers) {
+			t.Fatalf("%d vertices for %d distinct circle event centers", obs.vertices-obs.siteVertices, len(obs.centers))
+		}
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	bbox := NewBoundingBox(0, 10, 0, 10)
+	cases := []struct {
+		name  string
+		sites []Vertex
+		bbox  BoundingBox
+		err   error
+	}{
+		{"no sites", nil, bbox, ErrNoSites},
+		{"empty bbox", []Vertex{{1, 1}}, NewBoundingBox(5, 5, 0, 10), ErrInvalidBoundingBox},
+		{"nan bbox", []Vertex{{1, 1}}, NewBoundingBox(0, math.NaN(), 0, 10), ErrInvalidBoundingBox},
+		{"nan site", []Vertex{{1, 1}, {math.NaN(), 1}}, bbox, ErrInvalidSite},
+		{"inf site", []Vertex{{math.Inf(1), 1}}, bbox, ErrInvalidSite},
+		{"outside", []Vertex{{1, 1}, {11, 1}}, bbox, ErrSiteOutOfBounds},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Build(c.sites, c.bbox, Options{})
+			if !errors.Is(err, c.err) {
+				t.Fatalf("got %v, want %v", err, c.err)
+			}
+		})
+	}
+}
+
+// если конец ребра не лежит ни на одной стороне bbox, closeCells не может
+// замкнуть ячейку и должен сообщить об ошибке, а не вставлять полуребра вечно
+func TestCloseCellsFailsOnOpenEnd(t *testing.T) {
+	v := &Voronoi{cellsMap: make(map[Vertex]*Cell), eps: defaultEpsilon}
+	left := v.allocCell(Vertex{1, 1}, 0)
+	right := v.allocCell(Vertex{3, 1}, 1)
+	v.cells = []*Cell{left, right}
+	// ребро обрывается внутри bbox
+	v.createEdge(left, right, Vertex{2, 0}, Vertex{2, 1})
+
+	defer func() {
+		err, _ := recover().(error)
+		if !errors.Is(err, ErrCellNotClosed) {
+			t.Fatalf("got %v, want %v", err, ErrCellNotClosed)
+		}
+	}()
+	v.closeCells(NewBoundingBox(0, 4, 0, 4))
+}
+
+// допуск, заданный относительно bbox, работает на любом масштабе координат
+func TestBuildRelativeEpsilon(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	for _, size := range []float64{1e-5, 1, 4e6} {
+		bbox := NewBoundingBox(0, size, 0, size/2)
+		for k := 0; k < 20; k++ {
+			sites := randomSites(r, 2+r.Intn(300), bbox)
+			d, err := Build(sites, bbox, Options{CloseCells: true, RelativeEpsilon: 1e-12})
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkDiagram(t, d)
+		}
+	}
+}
+
+// большой допуск склейки не должен менять геометрию биссектрис: у сайтов с
+// почти равными Y ребро наклонное, а не вертикальное
+func TestBuildLargeEpsilonKeepsBisectors(t *testing.T) {
+	bbox := NewBoundingBox(0, 1000, 0, 1000)
+	sites := []Vertex{{490, 500}, {510, 500.9}}
+	d, err := Build(sites, bbox, Options{CloseCells: true, Epsilon: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inner *Edge
+	for _, e := range d.Edges {
+		if !e.IsBorder() {
+			inner = e
+		}
+	}
+	if inner == nil {
+		t.Fatal("no inner edge")
+	}
+	for _, p := range []Vertex{inner.Va.Vertex, inner.Vb.Vertex} {
+		dl := math.Sqrt(sqDist(p, sites[0]))
+		dr := math.Sqrt(sqDist(p, sites[1]))
+		if math.Abs(dl-dr) > 1e-6 {
+			t.Fatalf("endpoint %v: distances %v and %v", p, dl, dr)
+		}
+	}
+	a, b := inner.Endpoints()
+	if math.Abs(a.X-b.X) < 40 {
+		t.Fatalf("edge %v-%v is nearly vertical, want slope through (477.52, 1000)-(522.52, 0)", a, b)
+	}
+}
+
+func TestBuildInvalidTolerance(t *testing.T) {
+	bbox := NewBoundingBox(0, 10, 0, 10)
+	for _, opts := range []Options{{Epsilon: -1}, {Epsilon: math.NaN()}, {RelativeEpsilon: math.Inf(1)}} {
+		if _, err := Build([]Vertex{{1, 1}}, bbox, opts); !errors.Is(err, ErrInvalidTolerance) {
+			t.Fatalf("%+v: got %v, want %v", opts, err, ErrInvalidTolerance)
+		}
+	}
+}
+
+func TestBuildSitesIndexAndData(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	bbox := NewBoundingBox(0, 100, 0, 100)
+	points := randomSites(r, 200, bbox)
+	// дубликаты: ячейка достается первому вхождению
+	points = append(points, points[3], points[10])
+
+	sites := make([]Site, len(points))
+	for i, p := range points {
+		sites[i] = Site{Vertex: p, Data: fmt.Sprintf("station-%d", i)}
+	}
+	d, err := BuildSites(sites, bbox, Options{CloseCells: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Cells) != 200 {
+		t.Fatalf("got %d cells, want 200", len(d.Cells))
+	}
+	seen := make(map[int]bool)
+	for _, cell := range d.Cells {
+		i := cell.Index()
+		if i < 0 || i >= 200 || seen[i] {
+			t.Fatalf("bad index %d", i)
+		}
+		seen[i] = true
+		if cell.Site() != points[i] || cell.Data() != fmt.Sprintf("station-%d", i) {
+			t.Fatalf("cell %v: index %d, data %v", cell.Site(), i, cell.Data())
+		}
+	}
+}
+
+func TestBuildKeepsInputOrder(t *testing.T) {
+	r := rand.New(rand.NewSource(8))
+	bbox := NewBoundingBox(0, 100, 0, 100)
+	sites := randomSites(r, 300, bbox)
+	sites = append(sites, sites[5], sites[42])
+	orig := slices.Clone(sites)
+
+	d := mustBuild(t, sites, bbox)
+	if !slices.Equal(sites, orig) {
+		t.Fatal("Build reordered the input slice")
+	}
+	for i, site := range sites {
+		cell := d.CellOf(i)
+		if cell == nil || cell.Site() != site {
+			t.Fatalf("CellOf(%d) = %v, want cell of %v", i, cell, site)
+		}
+	}
+	if d.CellOf(300) != d.CellOf(5) || d.CellOf(301).Index() != 42 {
+		t.Fatal("duplicates must share the cell of the first occurrence")
+	}
+	if d.CellOf(-1) != nil || d.CellOf(len(sites)) != nil {
+		t.Fatal("CellOf out of range must be nil")
+	}
+}
+
+func TestGraphEuler(t *testing.T) {
+	r := rand.New(rand.NewSource(9))
+	bbox := NewBoundingBox(0, 100, 0, 100)
+	for _, sites := range [][]Vertex{randomSites(r, 300, bbox), gridSites(6, 6, bbox)} {
+		d := mustBuild(t, sites, bbox)
+		g := d.Graph()
+
+		// плоский связный граф: V - E + F = 2, грани - ячейки и внешняя
+		if v, e, f := len(g.Vertices), len(g.Edges), len(d.Cells)+1; v-e+f != 2 {
+			t.Fatalf("V=%d E=%d F=%d break Euler's formula", v, e, f)
+		}
+		degrees := 0
+		for _, v := range g.Vertices {
+			degrees += v.Degree()
+			if v.Degree() < 2 || (v.Degree() == 2 && !onBorder(v.Point, bbox, 1e-9)) {
+				t.Fatalf("vertex %v has degree %d", v.Point, v.Degree())
+			}
+			for _, id := range v.Edges {
+				e := g.Edges[id]
+				if g.Vertices[e.Other(v.ID)].Point == v.Point {
+					t.Fatalf("edge %d is a loop", id)
+				}
+			}
+		}
+		if degrees != 2*len(g.Edges) {
+			t.Fatalf("degree sum %d, want %d", degrees, 2*len(g.Edges))
+		}
+		for _, e := range g.Edges {
+			if len(e.Edge.Va.Edges) != g.Vertices[e.A].Degree() || len(e.Edge.Vb.Edges) != g.Vertices[e.B].Degree() {
+				t.Fatalf("EdgeVertex.Edges of edge %d not filled", e.ID)
+			}
+		}
+	}
+}
+
+func TestDCEL(t *testing.T) {
+	r := rand.New(rand.NewSource(10))
+	bbox := NewBoundingBox(0, 100, 0, 100)
+	cases := [][]Vertex{
+		randomSites(r, 300, bbox),
+		gridSites(6, 6, bbox),
+		{{50, 20}, {50, 40}, {50, 60}},
+		{{90, 50}, {50, 90}, {10, 50}, {50, 10}},
+		{{30, 60}},
+	}
+	for _, sites := range cases {
+		d := mustBuild(t, sites, bbox)
+		dcel, err := d.DCEL()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := dcel.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		for i, cell := range d.Cells {
+			if got := len(dcel.Faces[i].HalfEdges()); got != len(cell.HalfEdges()) {
+				t.Fatalf("face %d has %d half-edges, cell has %d", i, got, len(cell.HalfEdges()))
+			}
+		}
+		if v, e, f := len(dcel.Vertices), len(dcel.HalfEdges)/2, len(dcel.Faces)+1; v-e+f != 2 {
+			t.Fatalf("V=%d E=%d F=%d break Euler's formula", v, e, f)
+		}
+	}
+
+	d, err := Build([]Vertex{{20, 20}, {80, 30}, {40, 70}}, bbox, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.DCEL(); !errors.Is(err, ErrOpenCells) {
+		t.Fatalf("got %v, want %v", err, ErrOpenCells)
+	}
+}
+
+// запросы соседей сверяются с полным перебором по расстояниям:
+// на решетке много равных расстояний, и порядок среди них не определен
+func TestNeighborIndexMatchesBruteForce(t *testing.T) {
+	r := rand.New(rand.NewSource(11))
+	bbox := NewBoundingBox(0, 100, 0, 100)
+	for _, sites := range [][]Vertex{randomSites(r, 500, bbox), gridSites(10, 10, bbox), {{50, 50}}} {
+		d := mustBuild(t, sites, bbox)
+		ix := NewNeighborIndex(d)
+
+		for q := 0; q < 200; q++ {
+			// часть точек запроса - за пределами bbox
+			p := Vertex{r.Float64()*140 - 20, r.Float64()*140 - 20}
+			dists := make([]float64, len(d.Cells))
+			for i, cell := range d.Cells {
+				dists[i] = math.Sqrt(sqDist(p, cell.Site()))
+			}
+			slices.Sort(dists)
+
+			k := 1 + r.Intn(20)
+			got := ix.KNearest(p, k)
+			if len(got) != min(k, len(dists)) {
+				t.Fatalf("KNearest(%v, %d) returned %d sites", p, k, len(got))
+			}
+			for i, cell := range got {
+				if math.Abs(math.Sqrt(sqDist(p, cell.Site()))-dists[i]) > 1e-9 {
+					t.Fatalf("KNearest(%v, %d)[%d] = %v, want distance %v", p, k, i, cell.Site(), dists[i])
+				}
+			}
+			if ix.Nearest(p) != got[0] {
+				t.Fatalf("Nearest(%v) = %v, want %v", p, ix.Nearest(p).Site(), got[0].Site())
+			}
+
+			radius := r.Float64() * 30
+			want := 0
+			for _, dist := range dists {
+				if dist <= radius {
+					want++
+				}
+			}
+			if got := ix.WithinRadius(p, radius); len(got) != want {
+				t.Fatalf("WithinRadius(%v, %v) returned %d sites, want %d", p, radius, len(got), want)
+			}
+		}
+
+		p := Vertex{50, 50}
+		for _, k := range []int{0, -1} {
+			if got := ix.KNearest(p, k); got != nil {
+				t.Fatalf("KNearest(%v, %d) returned %d sites, want none", p, k, len(got))
+			}
+		}
+		if got := ix.WithinRadius(p, -10); got != nil {
+			t.Fatalf("WithinRadius(%v, -10) returned %d sites, want none", p, len(got))
+		}
+	}
+}
+
+func TestNeighborIndexNeighbors(t *testing.T) {
+	r := rand.New(rand.NewSource(12))
+	bbox := NewBoundingBox(0, 100, 0, 100)
+	d := mustBuild(t, randomSites(r, 200, bbox), bbox)
+	ix := NewNeighborIndex(d)
+	for _, cell := range d.Cells {
+		natural := ix.Neighbors(cell)
+		// соседи внутри bbox - подмножество естественных соседей
+		for _, n := range cell.Neighbors() {
+			if !slices.Contains(natural, n) {
+				t.Fatalf("cell %v: neighbor %v missing from natural neighbors", cell.Site(), n.Site())
+			}
+		}
+	}
+}
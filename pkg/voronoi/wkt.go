@@ -0,0 +1,28 @@
+package voronoi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// WriteWKT пишет ячейки диаграммы в WKT, по одному POLYGON на строку в порядке Diagram.Cells.
+// Ячейки без многоугольника пишутся как POLYGON EMPTY, чтобы номер строки совпадал с индексом ячейки
+func (d *Diagram) WriteWKT(w io.Writer) error {
+	var buf bytes.Buffer
+	for _, cell := range d.Cells {
+		polygon := cell.Polygon()
+		if len(polygon) < 3 {
+			buf.WriteString("POLYGON EMPTY\n")
+			continue
+		}
+		buf.WriteString("POLYGON ((")
+		for _, p := range polygon {
+			fmt.Fprintf(&buf, "%g %g, ", p.X, p.Y)
+		}
+		// кольцо в WKT замкнуто
+		fmt.Fprintf(&buf, "%g %g))\n", polygon[0].X, polygon[0].Y)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
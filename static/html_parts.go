@@ -25,6 +25,7 @@ var (
 				width: 50%;
 				padding: 10px;
 				box-sizing: border-box;
+				overflow-y: auto; /* Прокрутка для таблицы покрытия */
 			}
 
 			#right-container {
@@ -44,6 +45,19 @@ var (
 				font-family: Consolas, monospace; /* Моноширинный шрифт для логов */
 			}
 
+			#coverage {
+				border-collapse: collapse;
+				margin-top: 10px;
+				font-size: 12px;
+			}
+
+			#coverage th,
+			#coverage td {
+				border: 1px solid #444;
+				padding: 2px 8px;
+				text-align: right;
+			}
+
 			#chart-container {
 				width: 100%;
 				height: 400px;
@@ -98,12 +112,18 @@ var (
                     <input type="number" id="height" name="height" value="1000" min="100" max="5000"><br>
                     <label for="stations">Количество станций (n):</label>
                     <input type="number" id="stations" name="stations" value="12" min="1" max="200"><br>
+                    <label for="relax">Релаксация Ллойда (итераций):</label>
+                    <input type="number" id="relax" name="relax" value="0" min="0" max="50"><br>
 	
 					<label for="random">Генерировать случайные станции?</label>
 					<input type="checkbox" id="random" name="random" value="true"><br>
 
+					<label for="delaunay">Показать триангуляцию Делоне?</label>
+					<input type="checkbox" id="delaunay" name="delaunay" value="true"><br>
+
                     <input type="submit" value="Построить">
                 </form>
+                <a href="/steps" style="color: #80b1d3;">Алгоритм по шагам</a>
     `
 
 	Part2 = `
@@ -0,0 +1,234 @@
+package static
+
+// Страница пошагового проигрывания алгоритма Форчуна.
+// Снимки берутся из /api/steps и рисуются на canvas
+var StepsPage = `
+<!DOCTYPE html>
+<html>
+<head>
+    <title>Алгоритм Форчуна по шагам</title>
+    <style>
+        body {
+            background-color: #1F1F1F;
+            color: #d3d3d3;
+            font-family: Consolas, monospace;
+        }
+
+        canvas {
+            background-color: #2b2b2b;
+            border: 1px solid #444;
+        }
+
+        input,
+        button {
+            background-color: #2b2b2b;
+            color: #d3d3d3;
+            border: 1px solid #444;
+            padding: 5px;
+            margin: 5px 2px;
+            border-radius: 4px;
+        }
+
+        button:hover {
+            background-color: #444;
+            cursor: pointer;
+        }
+
+        #step-info {
+            margin: 5px 0;
+        }
+    </style>
+</head>
+<body>
+    <h1>Алгоритм Форчуна по шагам</h1>
+    <form id="steps-form">
+        <label for="width">Ширина (W):</label>
+        <input type="number" id="width" name="width" value="1000" min="100" max="5000">
+        <label for="height">Высота (H):</label>
+        <input type="number" id="height" name="height" value="1000" min="100" max="5000">
+        <label for="stations">Станций (n):</label>
+        <input type="number" id="stations" name="stations" value="12" min="1" max="200">
+        <label for="random">Случайные</label>
+        <input type="checkbox" id="random" name="random" value="true">
+        <input type="submit" value="Построить">
+    </form>
+    <div>
+        <button id="first">|&lt;</button>
+        <button id="prev">&lt;</button>
+        <button id="play">Пуск</button>
+        <button id="next">&gt;</button>
+        <button id="last">&gt;|</button>
+        <input type="range" id="slider" min="0" max="0" value="0">
+        <label for="speed">Задержка, мс:</label>
+        <input type="number" id="speed" value="500" min="20" max="5000" step="20">
+    </div>
+    <div id="step-info"></div>
+    <canvas id="canvas" width="800" height="800"></canvas>
+
+    <script>
+        const canvas = document.getElementById('canvas');
+        const ctx = canvas.getContext('2d');
+        const slider = document.getElementById('slider');
+        const info = document.getElementById('step-info');
+        const playBtn = document.getElementById('play');
+
+        let data = null;
+        let current = 0;
+        let timer = null;
+
+        // перевод координат диаграммы в пиксели canvas
+        function tx(x) { return (x - data.bbox.xl) / (data.bbox.xr - data.bbox.xl) * canvas.width; }
+        function ty(y) { return (y - data.bbox.yt) / (data.bbox.yb - data.bbox.yt) * canvas.height; }
+
+        function parabolaY(site, x, directrix) {
+            const dx = x - site.X;
+            return (dx * dx + site.Y * site.Y - directrix * directrix) / (2 * (site.Y - directrix));
+        }
+
+        function draw() {
+            ctx.clearRect(0, 0, canvas.width, canvas.height);
+            if (!data || data.steps.length === 0) {
+                return;
+            }
+            const step = data.steps[current];
+            slider.value = current;
+            info.textContent = 'Шаг ' + (current + 1) + ' из ' + data.steps.length +
+                ': ' + ({site: 'событие точки', circle: 'событие круга', done: 'обрезка по bbox'})[step.event] +
+                ', прямая сканирования y = ' + step.sweep.toFixed(2);
+
+            // ребра
+            ctx.strokeStyle = '#80b1d3';
+            ctx.lineWidth = 2;
+            for (const e of step.edges || []) {
+                ctx.beginPath();
+                ctx.moveTo(tx(e[0].X), ty(e[0].Y));
+                ctx.lineTo(tx(e[1].X), ty(e[1].Y));
+                ctx.stroke();
+            }
+
+            // события круга
+            ctx.strokeStyle = '#fb8072';
+            ctx.lineWidth = 1;
+            for (const c of step.circleEvents || []) {
+                const r = c.radius / (data.bbox.xr - data.bbox.xl) * canvas.width;
+                ctx.beginPath();
+                ctx.arc(tx(c.center.X), ty(c.center.Y), r, 0, 2 * Math.PI);
+                ctx.stroke();
+            }
+
+            // пляжная линия
+            ctx.strokeStyle = '#b3de69';
+            ctx.lineWidth = 2;
+            for (const arc of step.arcs || []) {
+                ctx.beginPath();
+                if (arc.site.Y === step.sweep) {
+                    // вырожденная парабола - вертикальный луч
+                    ctx.moveTo(tx(arc.site.X), ty(arc.site.Y));
+                    ctx.lineTo(tx(arc.site.X), 0);
+                } else {
+                    const n = 50;
+                    for (let i = 0; i <= n; i++) {
+                        const x = arc.from + (arc.to - arc.from) * i / n;
+                        const y = parabolaY(arc.site, x, step.sweep);
+                        if (i === 0) {
+                            ctx.moveTo(tx(x), ty(y));
+                        } else {
+                            ctx.lineTo(tx(x), ty(y));
+                        }
+                    }
+                }
+                ctx.stroke();
+            }
+
+            // прямая сканирования
+            ctx.strokeStyle = '#ffed6f';
+            ctx.lineWidth = 1;
+            ctx.beginPath();
+            ctx.moveTo(0, ty(step.sweep));
+            ctx.lineTo(canvas.width, ty(step.sweep));
+            ctx.stroke();
+
+            // сайты
+            for (const s of data.sites) {
+                ctx.fillStyle = s.Y <= step.sweep ? 'lightgreen' : '#757575';
+                ctx.beginPath();
+                ctx.arc(tx(s.X), ty(s.Y), 4, 0, 2 * Math.PI);
+                ctx.fill();
+            }
+
+            // точка текущего события
+            if (step.event !== 'done') {
+                ctx.fillStyle = step.event === 'site' ? 'white' : '#fb8072';
+                ctx.beginPath();
+                ctx.arc(tx(step.point.X), ty(step.point.Y), 6, 0, 2 * Math.PI);
+                ctx.fill();
+            }
+        }
+
+        function go(i) {
+            if (!data) {
+                return;
+            }
+            current = Math.max(0, Math.min(data.steps.length - 1, i));
+            draw();
+        }
+
+        function stop() {
+            clearInterval(timer);
+            timer = null;
+            playBtn.textContent = 'Пуск';
+        }
+
+        document.getElementById('first').onclick = () => { stop(); go(0); };
+        document.getElementById('prev').onclick = () => { stop(); go(current - 1); };
+        document.getElementById('next').onclick = () => { stop(); go(current + 1); };
+        document.getElementById('last').onclick = () => { stop(); go(data ? data.steps.length - 1 : 0); };
+        slider.oninput = () => { stop(); go(parseInt(slider.value)); };
+        playBtn.onclick = () => {
+            if (timer) {
+                stop();
+                return;
+            }
+            if (data && current === data.steps.length - 1) {
+                go(0);
+            }
+            playBtn.textContent = 'Пауза';
+            timer = setInterval(() => {
+                if (!data || current >= data.steps.length - 1) {
+                    stop();
+                    return;
+                }
+                go(current + 1);
+            }, parseInt(document.getElementById('speed').value));
+        };
+
+        document.getElementById('steps-form').addEventListener('submit', function (e) {
+            e.preventDefault();
+            stop();
+            const params = new URLSearchParams(new FormData(this)).toString();
+            fetch('/api/steps?' + params)
+                .then(response => {
+                    if (!response.ok) {
+                        return response.text().then(text => { throw new Error(text); });
+                    }
+                    return response.json();
+                })
+                .then(json => {
+                    data = json;
+                    // сохраняем пропорции bbox
+                    const w = data.bbox.xr - data.bbox.xl;
+                    const h = data.bbox.yb - data.bbox.yt;
+                    canvas.height = Math.round(canvas.width * h / w);
+                    slider.max = data.steps.length - 1;
+                    go(0);
+                })
+                .catch(error => {
+                    info.textContent = 'Ошибка: ' + error.message;
+                });
+        });
+
+        document.getElementById('steps-form').requestSubmit();
+    </script>
+</body>
+</html>
+`